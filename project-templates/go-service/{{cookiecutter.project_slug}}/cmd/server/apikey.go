@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// apiKeyHeader carries API keys. Keys never go in the query string, where
+// they would end up in access logs.
+const apiKeyHeader = "X-API-Key"
+
+// ErrUnknownAPIKey is returned by a KeyStore for a key it doesn't hold,
+// including revoked keys.
+var ErrUnknownAPIKey = errors.New("invalid API key")
+
+// KeyStore resolves API keys to the principal they act as. Implement it
+// against your key database and pass it to buildRouter; the built-in
+// hashedKeyStore only covers --api-keys.
+type KeyStore interface {
+	LookupAPIKey(ctx context.Context, key string) (Principal, error)
+}
+
+// HashAPIKey returns the hex SHA-256 digest of key, the form in which
+// --api-keys stores keys:
+//
+//	printf %s "$KEY" | sha256sum
+//
+// Keys are long and random, so a fast unsalted hash is enough to keep a
+// leaked config from leaking the keys.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashedKeyStore holds principals by key hash. Looking up by hash means the
+// map lookup's timing can't reveal anything about stored keys.
+type hashedKeyStore struct {
+	principals map[string]Principal
+}
+
+// newHashedKeyStore parses "subject:sha256-hex[:scope]" entries, as in
+// --api-keys. The optional scope is space-delimited, as in the scope claim.
+// Malformed entries are reported by position, not content, in case a raw
+// key was pasted in by mistake.
+func newHashedKeyStore(entries []string) (*hashedKeyStore, error) {
+	principals := make(map[string]Principal, len(entries))
+	seen := make(map[string]int, len(entries)) // hash to entry number
+	for i, e := range entries {
+		n := i + 1
+		subject, rest, ok := strings.Cut(e, ":")
+		hash, scope, _ := strings.Cut(rest, ":")
+		if !ok || subject == "" {
+			return nil, fmt.Errorf("api key entry %d must be subject:sha256-hex[:scope]", n)
+		}
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("api key for %q: hash must be 64 hex characters", subject)
+		}
+		hash = strings.ToLower(hash)
+		if first, dup := seen[hash]; dup {
+			return nil, fmt.Errorf("api key entries %d and %d have the same hash", first, n)
+		}
+		seen[hash] = n
+		principals[hash] = Principal{Subject: subject, Scope: scope}
+	}
+	return &hashedKeyStore{principals: principals}, nil
+}
+
+func (s *hashedKeyStore) LookupAPIKey(_ context.Context, key string) (Principal, error) {
+	p, ok := s.principals[HashAPIKey(key)]
+	if !ok {
+		return Principal{}, ErrUnknownAPIKey
+	}
+	return p, nil
+}
+
+// withAPIKey authenticates requests by the key in the X-API-Key header.
+// Like withJWTAuth, it stores claims for the key's principal in the
+// request context, so scope checks and handlers don't care which was used.
+func withAPIKey(store KeyStore) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(apiKeyHeader)
+			if key == "" {
+				writeProblem(w, r, problemUnauthorized("missing API key"))
+				return
+			}
+
+			principal, err := store.LookupAPIKey(r.Context(), key)
+			if errors.Is(err, ErrUnknownAPIKey) {
+				writeProblem(w, r, problemUnauthorized(ErrUnknownAPIKey.Error()))
+				return
+			}
+			if err != nil {
+				LoggerFromContext(r.Context()).ErrorContext(r.Context(), "looking up API key", "error", err)
+				writeProblem(w, r, problemInternal("internal server error"))
+				return
+			}
+
+			raw := jwt.MapClaims{"sub": principal.Subject}
+			if principal.Scope != "" {
+				raw["scope"] = principal.Scope
+			}
+			ctx := context.WithValue(r.Context(), claimsKey, newClaims(raw))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// withAPIKeyOrAuth sends requests carrying an X-API-Key header to apiKey
+// and all others to other, so protected routes accept either.
+func withAPIKeyOrAuth(apiKey, other adapter) adapter {
+	return func(next http.Handler) http.Handler {
+		apiKeyNext, otherNext := apiKey(next), other(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(apiKeyHeader) != "" {
+				apiKeyNext.ServeHTTP(w, r)
+				return
+			}
+			otherNext.ServeHTTP(w, r)
+		})
+	}
+}
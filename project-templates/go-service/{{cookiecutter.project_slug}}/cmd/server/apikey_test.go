@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// failingKeyStore stands in for a key database that is down.
+type failingKeyStore struct{}
+
+func (failingKeyStore) LookupAPIKey(context.Context, string) (Principal, error) {
+	return Principal{}, errors.New("connection refused")
+}
+
+func TestAPIKey(t *testing.T) {
+	store, err := newHashedKeyStore([]string{"partner-a:" + strings.ToUpper(HashAPIKey("k-123")) + ":read write"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *Claims
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ClaimsFromContext(r.Context())
+	})
+
+	tests := []struct {
+		name       string
+		store      KeyStore
+		key        string
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "known key", store: store, key: "k-123", wantStatus: http.StatusOK},
+		{name: "unknown key", store: store, key: "k-124", wantStatus: http.StatusUnauthorized, wantDetail: "invalid API key"},
+		{name: "missing key", store: store, wantStatus: http.StatusUnauthorized, wantDetail: "missing API key"},
+		{name: "store failure", store: failingKeyStore{}, key: "k-123", wantStatus: http.StatusInternalServerError, wantDetail: "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rec := httptest.NewRecorder()
+			withAPIKey(tt.store)(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if detail := errorMessage(t, rec.Body.Bytes()); detail != tt.wantDetail {
+					t.Errorf("detail = %q, want %q", detail, tt.wantDetail)
+				}
+				return
+			}
+			if got == nil || got.Subject != "partner-a" || !got.HasScope("write") {
+				t.Errorf("claims = %+v, want subject partner-a with scope write", got)
+			}
+		})
+	}
+}
+
+func TestAPIKeyOrAuth(t *testing.T) {
+	store, err := newHashedKeyStore([]string{"partner-a:" + HashAPIKey("k-123")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := withAPIKeyOrAuth(withAPIKey(store), withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}))(okHandler)
+
+	withKey := httptest.NewRequest(http.MethodGet, "/", nil)
+	withKey.Header.Set("X-API-Key", "k-123")
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{name: "api key", req: withKey, wantStatus: http.StatusOK},
+		{name: "bearer", req: authedRequest(t, http.MethodGet, "/", jwt.MapClaims{"sub": "u1"}), wantStatus: http.StatusOK},
+		{name: "neither", req: httptest.NewRequest(http.MethodGet, "/", nil), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNewHashedKeyStoreErrors(t *testing.T) {
+	const rawKey = "sk-live-0123456789abcdef"
+	hash := HashAPIKey("k-123")
+	tests := []struct {
+		name    string
+		entries []string
+		want    string
+	}{
+		{name: "raw key pasted", entries: []string{"partner-a:" + hash, rawKey}, want: "api key entry 2 must be subject:sha256-hex[:scope]"},
+		{name: "empty subject", entries: []string{":" + hash}, want: "api key entry 1 must be subject:sha256-hex[:scope]"},
+		{name: "not hashed", entries: []string{"partner-a:k-123"}, want: `api key for "partner-a": hash must be 64 hex characters`},
+		{
+			name:    "duplicate hash",
+			entries: []string{"partner-a:" + hash, "partner-b:" + hash, "partner-c:" + strings.ToUpper(hash)},
+			want:    "api key entries 1 and 2 have the same hash",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newHashedKeyStore(tt.entries)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if strings.Contains(err.Error(), rawKey) {
+				t.Errorf("err %q leaks the entry", err)
+			}
+		})
+	}
+}
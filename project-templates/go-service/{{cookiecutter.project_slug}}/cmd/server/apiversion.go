@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// defaultAPIVersion is the version unversioned API paths redirect to.
+// Bump it once clients have moved to a new version.
+const defaultAPIVersion = "v1"
+
+// apiVersionKey holds the version of the route group that matched.
+const apiVersionKey contextKey = "api_version"
+
+// apiGroup registers API routes under a version prefix, so /v1/whoami and
+// /v2/whoami can be served by different handlers while old clients keep
+// working. Handlers read the version from APIVersionFromContext.
+type apiGroup struct {
+	mux     routeMux
+	version string
+}
+
+func newAPIGroup(mux routeMux, version string) apiGroup {
+	return apiGroup{mux: mux, version: version}
+}
+
+// Handle registers h for pattern ("GET /whoami") under the group's prefix
+// ("GET /v1/whoami"), documented with doc. In the defaultAPIVersion group
+// it also registers the unversioned pattern as a redirect to the versioned
+// path.
+func (g apiGroup) Handle(pattern string, doc routeDoc, h http.Handler) {
+	versioned := g.Pattern(pattern)
+	g.mux.Handle(versioned, doc, withAPIVersion(g.version)(h))
+	if g.version == defaultAPIVersion {
+		_, path, _ := strings.Cut(versioned, " ")
+		g.mux.Handle(pattern, routeDoc{Summary: "Redirect to " + path, Status: http.StatusPermanentRedirect},
+			redirectToVersion("/"+g.version))
+	}
+}
+
+// Pattern returns the versioned form of pattern that Handle registers.
+func (g apiGroup) Pattern(pattern string) string {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("api route %q must be METHOD /path", pattern))
+	}
+	return method + " /" + g.version + path
+}
+
+// redirectToVersion redirects to the same path and query under prefix.
+// 308 keeps the method and body, so it works for POSTs too.
+func redirectToVersion(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := prefix + r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// withAPIVersion records version in the request context.
+func withAPIVersion(version string) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), apiVersionKey, version)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// APIVersionFromContext returns the version of the API route serving the
+// request, such as "v1", or "" outside versioned routes.
+func APIVersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(apiVersionKey).(string)
+	return version
+}
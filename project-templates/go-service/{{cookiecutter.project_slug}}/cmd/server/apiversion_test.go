@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIGroups(t *testing.T) {
+	versioned := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", name)
+			w.Header().Set("X-API-Version", APIVersionFromContext(r.Context()))
+		})
+	}
+	mux := newRouteMux(newAPISpec())
+	newAPIGroup(mux, "v1").Handle("GET /items/{id}", routeDoc{}, versioned("items-v1"))
+	newAPIGroup(mux, "v2").Handle("GET /items/{id}", routeDoc{}, versioned("items-v2"))
+
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantHandler  string
+		wantVersion  string
+		wantLocation string
+	}{
+		{name: "v1", target: "/v1/items/7", wantStatus: http.StatusOK, wantHandler: "items-v1", wantVersion: "v1"},
+		{name: "v2", target: "/v2/items/7", wantStatus: http.StatusOK, wantHandler: "items-v2", wantVersion: "v2"},
+		{name: "unversioned redirects to default", target: "/items/7?full=1", wantStatus: http.StatusPermanentRedirect, wantLocation: "/v1/items/7?full=1"},
+		{name: "unknown version", target: "/v3/items/7", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(mux.mux, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Handler"); got != tt.wantHandler {
+				t.Errorf("handler = %q, want %q", got, tt.wantHandler)
+			}
+			if got := rec.Header().Get("X-API-Version"); got != tt.wantVersion {
+				t.Errorf("version = %q, want %q", got, tt.wantVersion)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestAPIGroupRejectsBadPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Handle with a pattern without a method did not panic")
+		}
+	}()
+	newAPIGroup(newRouteMux(newAPISpec()), "v1").Handle("/whoami", routeDoc{}, http.NotFoundHandler())
+}
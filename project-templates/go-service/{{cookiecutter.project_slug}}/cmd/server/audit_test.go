@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	h := adaptHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}),
+		withRequestID(func() string { return "req-1" }),
+		withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}),
+		withAudit(slog.New(slog.NewJSONHandler(&buf, nil))),
+	)
+
+	serve(h, authedRequest(t, http.MethodPost, "/v1/reports", jwt.MapClaims{"sub": "u1"}))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding audit record %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":        "audit",
+		"subject":    "u1",
+		"method":     "POST",
+		"path":       "/v1/reports",
+		"status":     float64(http.StatusAccepted),
+		"request_id": "req-1",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("%s = %v, want %v", k, record[k], v)
+		}
+	}
+
+	buf.Reset()
+	serve(h, httptest.NewRequest(http.MethodPost, "/v1/reports", nil))
+	if buf.Len() != 0 {
+		t.Errorf("unauthenticated request audited: %s", buf.String())
+	}
+}
+
+func TestAuditLoggerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, closeAudit, err := newAuditLogger(path, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("audit", "subject", "u1")
+	if err := closeAudit(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"log_type":"audit"`)) || !bytes.Contains(b, []byte(`"subject":"u1"`)) {
+		t.Errorf("audit log = %s", b)
+	}
+}
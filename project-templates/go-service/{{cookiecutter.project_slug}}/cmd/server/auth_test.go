@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// okHandler answers 200 with an empty body.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+// errorMessage decodes a problem+json body and returns its detail.
+func errorMessage(t *testing.T, body []byte) string {
+	t.Helper()
+	var p problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("decoding error %q: %v", body, err)
+	}
+	return p.Detail
+}
+
+func TestJWTRegisteredClaims(t *testing.T) {
+	h := withJWTAuth(jwtAuthOptions{
+		Keyfunc:  hmacKeyfunc([]byte(testJWTSecret)),
+		Issuer:   "https://issuer.example",
+		Audience: "svc",
+	})(okHandler)
+
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		wantStatus int
+		wantDetail string
+	}{
+		{
+			name:       "valid",
+			claims:     jwt.MapClaims{"sub": "u1", "iss": "https://issuer.example", "aud": "svc"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong audience",
+			claims:     jwt.MapClaims{"sub": "u1", "iss": "https://issuer.example", "aud": "other"},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid audience",
+		},
+		{
+			name:       "missing issuer",
+			claims:     jwt.MapClaims{"sub": "u1", "aud": "svc"},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid issuer",
+		},
+		{
+			name:       "wrong issuer",
+			claims:     jwt.MapClaims{"sub": "u1", "iss": "https://evil.example", "aud": "svc"},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid issuer",
+		},
+		{
+			name:       "expired",
+			claims:     jwt.MapClaims{"sub": "u1", "iss": "https://issuer.example", "aud": "svc", "exp": time.Now().Add(-time.Hour).Unix()},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, authedRequest(t, http.MethodGet, "/whoami", tt.claims))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantDetail == "" {
+				return
+			}
+			if got := errorMessage(t, rec.Body.Bytes()); got != tt.wantDetail {
+				t.Errorf("error = %q, want %q", got, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestJWTLeeway(t *testing.T) {
+	// Minted on a clock running 5s ahead of ours.
+	now := time.Now()
+	claims := jwt.MapClaims{"sub": "u1", "nbf": now.Add(5 * time.Second).Unix(), "iat": now.Add(5 * time.Second).Unix()}
+	expired := jwt.MapClaims{"sub": "u1", "exp": now.Add(-time.Minute).Unix()}
+
+	tests := []struct {
+		name       string
+		leeway     time.Duration
+		claims     jwt.MapClaims
+		wantStatus int
+	}{
+		{name: "future nbf within leeway", leeway: 30 * time.Second, claims: claims, wantStatus: http.StatusOK},
+		{name: "future nbf without leeway", claims: claims, wantStatus: http.StatusUnauthorized},
+		{name: "expired beyond leeway", leeway: 30 * time.Second, claims: expired, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret)), Leeway: tt.leeway})(okHandler)
+			rec := serve(h, authedRequest(t, http.MethodGet, "/whoami", tt.claims))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestJWTAuthorizationHeader(t *testing.T) {
+	h := withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))})(okHandler)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "valid", header: "Bearer " + testToken(t, jwt.MapClaims{"sub": "u1"}), wantStatus: http.StatusOK},
+		{name: "lowercase scheme", header: "bearer " + testToken(t, jwt.MapClaims{"sub": "u1"}), wantStatus: http.StatusOK},
+		{name: "missing", wantStatus: http.StatusUnauthorized, wantDetail: "missing authorization header"},
+		{name: "basic scheme", header: "Basic dTE6cHc=", wantStatus: http.StatusUnauthorized, wantDetail: "authorization scheme must be Bearer"},
+		{name: "token without scheme", header: testToken(t, jwt.MapClaims{"sub": "u1"}), wantStatus: http.StatusUnauthorized, wantDetail: "authorization scheme must be Bearer"},
+		{name: "empty token", header: "Bearer ", wantStatus: http.StatusUnauthorized, wantDetail: "empty bearer token"},
+		{name: "scheme only", header: "Bearer", wantStatus: http.StatusUnauthorized, wantDetail: "empty bearer token"},
+		{name: "malformed token", header: "Bearer not-a-jwt", wantStatus: http.StatusUnauthorized, wantDetail: "malformed token"},
+		{name: "bad signature", header: "Bearer " + testToken(t, jwt.MapClaims{"sub": "u1"}) + "x", wantStatus: http.StatusUnauthorized, wantDetail: "invalid token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := serve(h, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantDetail == "" {
+				return
+			}
+			if got := errorMessage(t, rec.Body.Bytes()); got != tt.wantDetail {
+				t.Errorf("error = %q, want %q", got, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	h := withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))})(withRequireScope("admin")(okHandler))
+
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		wantStatus int
+	}{
+		{name: "scope granted", claims: jwt.MapClaims{"sub": "ops", "scope": "read admin"}, wantStatus: http.StatusOK},
+		{name: "role granted", claims: jwt.MapClaims{"sub": "ops", "roles": []interface{}{"viewer", "admin"}}, wantStatus: http.StatusOK},
+		{name: "single role granted", claims: jwt.MapClaims{"sub": "ops", "roles": "admin"}, wantStatus: http.StatusOK},
+		{name: "scope lacking", claims: jwt.MapClaims{"sub": "u1", "scope": "read write"}, wantStatus: http.StatusForbidden},
+		{name: "no scope claim", claims: jwt.MapClaims{"sub": "u1"}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, authedRequest(t, http.MethodGet, "/admin", tt.claims))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestJWTCookie(t *testing.T) {
+	h := withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret)), Cookie: "access_token"})(okHandler)
+	token := testToken(t, jwt.MapClaims{"sub": "u1"})
+
+	tests := []struct {
+		name       string
+		header     string
+		cookie     *http.Cookie
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "token in header", header: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "token in cookie", cookie: &http.Cookie{Name: "access_token", Value: token}, wantStatus: http.StatusOK},
+		{
+			name:       "header takes precedence",
+			header:     "Bearer not-a-jwt",
+			cookie:     &http.Cookie{Name: "access_token", Value: token},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "malformed token",
+		},
+		{
+			name:       "invalid token in cookie",
+			cookie:     &http.Cookie{Name: "access_token", Value: token + "x"},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid token",
+		},
+		{
+			name:       "other cookie",
+			cookie:     &http.Cookie{Name: "session", Value: token},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "missing authorization header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			rec := serve(h, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantDetail == "" {
+				return
+			}
+			if got := errorMessage(t, rec.Body.Bytes()); got != tt.wantDetail {
+				t.Errorf("error = %q, want %q", got, tt.wantDetail)
+			}
+		})
+	}
+}
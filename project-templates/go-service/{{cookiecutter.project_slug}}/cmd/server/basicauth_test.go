@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestBasicAuth(t *testing.T) {
+	var gotSubject string
+	h := withBasicAuth(map[string]string{"ci": "s3cret:with-colon"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		gotSubject = claims.Subject
+	}))
+
+	tests := []struct {
+		name       string
+		setAuth    func(r *http.Request)
+		wantStatus int
+		wantDetail string
+	}{
+		{
+			name:       "valid",
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("ci", "s3cret:with-colon") },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong password",
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("ci", "s3cret") },
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid credentials",
+		},
+		{
+			name:       "unknown user",
+			setAuth:    func(r *http.Request) { r.SetBasicAuth("mallory", "") },
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid credentials",
+		},
+		{
+			name:       "missing",
+			setAuth:    func(r *http.Request) {},
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "missing basic credentials",
+		},
+		{
+			name:       "bearer token",
+			setAuth:    func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc") },
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "missing basic credentials",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSubject = ""
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setAuth(req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if gotSubject != "ci" {
+					t.Errorf("subject = %q, want %q", gotSubject, "ci")
+				}
+				return
+			}
+			if got := errorMessage(t, rec.Body.Bytes()); got != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", got, tt.wantDetail)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got == "" {
+				t.Error("no WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestBasicOrJWTAuthRoutes(t *testing.T) {
+	h := testRouter(t, func(cfg *serverConfig) { cfg.BasicAuthUsers = []string{"ci:s3cret"} })
+
+	basic := httptest.NewRequest(http.MethodGet, "/v1/whoami", nil)
+	basic.SetBasicAuth("ci", "s3cret")
+	wrong := httptest.NewRequest(http.MethodGet, "/v1/whoami", nil)
+	wrong.SetBasicAuth("ci", "guess")
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{name: "basic", req: basic, wantStatus: http.StatusOK},
+		{name: "wrong basic", req: wrong, wantStatus: http.StatusUnauthorized},
+		{name: "bearer", req: authedRequest(t, http.MethodGet, "/v1/whoami", jwt.MapClaims{"sub": "u1"}), wantStatus: http.StatusOK},
+		{name: "none", req: httptest.NewRequest(http.MethodGet, "/v1/whoami", nil), wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tt.req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestParseBasicAuthUsers(t *testing.T) {
+	users, err := parseBasicAuthUsers([]string{"ci:a:b", "ops:pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users["ci"] != "a:b" || users["ops"] != "pw" {
+		t.Errorf("users = %v", users)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// bodyLoggingOptions configures withBodyLogging.
+type bodyLoggingOptions struct {
+	// MaxBytes caps how much of each body is logged.
+	MaxBytes int
+	// Redact rewrites a captured body before it's logged. Nil logs bodies
+	// as captured.
+	Redact func(body []byte) []byte
+}
+
+// withBodyLogging logs request and response bodies, up to opts.MaxBytes
+// each, in a debug record per request. It's for debugging integrations: it
+// does nothing unless the request logger has debug enabled, and bodies can
+// carry credentials and personal data, so pair it with a Redact func. Add
+// it after withLogging so the record carries the request ID.
+//
+// Handlers still see the whole request body: the logged prefix is read
+// ahead and replayed in front of the rest.
+func withBodyLogging(opts bodyLoggingOptions) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := LoggerFromContext(r.Context())
+			if !logger.Enabled(r.Context(), slog.LevelDebug) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Read one byte past the cap to tell a body of exactly MaxBytes
+			// from a truncated one.
+			reqBody, _ := io.ReadAll(io.LimitReader(r.Body, int64(opts.MaxBytes)+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+
+			cw := &bodyCaptureWriter{ResponseWriter: w, max: opts.MaxBytes}
+			next.ServeHTTP(cw, r)
+
+			reqLogged, reqTruncated := capBody(reqBody, opts.MaxBytes)
+			logger.LogAttrs(r.Context(), slog.LevelDebug, "bodies",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("request_body", string(redactBody(opts.Redact, reqLogged, reqTruncated))),
+				slog.Bool("request_body_truncated", reqTruncated),
+				slog.String("response_body", string(redactBody(opts.Redact, cw.buf.Bytes(), cw.truncated))),
+				slog.Bool("response_body_truncated", cw.truncated),
+			)
+		})
+	}
+}
+
+func capBody(b []byte, max int) ([]byte, bool) {
+	if len(b) > max {
+		return b[:max], true
+	}
+	return b, false
+}
+
+func redactBody(redact func([]byte) []byte, body []byte, truncated bool) []byte {
+	if redact == nil || len(body) == 0 {
+		return body
+	}
+	if truncated {
+		// A cut-off body can't be parsed, so it can't be redacted reliably.
+		return []byte("[TRUNCATED BODY REDACTED]")
+	}
+	return redact(body)
+}
+
+// redactJSONFields returns a Redact func that replaces the values of JSON
+// object fields named in keys (case-insensitive), at any depth, with
+// "[REDACTED]". Bodies that aren't JSON are left alone.
+func redactJSONFields(keys []string) func([]byte) []byte {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = true
+	}
+	var redact func(v interface{}) interface{}
+	redact = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, fv := range v {
+				if set[strings.ToLower(k)] {
+					v[k] = "[REDACTED]"
+				} else {
+					v[k] = redact(fv)
+				}
+			}
+		case []interface{}:
+			for i, ev := range v {
+				v[i] = redact(ev)
+			}
+		}
+		return v
+	}
+	return func(body []byte) []byte {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return body
+		}
+		out, err := json.Marshal(redact(v))
+		if err != nil {
+			return body
+		}
+		return out
+	}
+}
+
+// bodyCaptureWriter keeps the first max bytes of the response body.
+type bodyCaptureWriter struct {
+	http.ResponseWriter
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (cw *bodyCaptureWriter) Write(b []byte) (int, error) {
+	if room := cw.max - cw.buf.Len(); room < len(b) {
+		cw.buf.Write(b[:max(room, 0)])
+		cw.truncated = true
+	} else {
+		cw.buf.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush passes through to the underlying writer for streaming responses.
+func (cw *bodyCaptureWriter) Flush() {
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *bodyCaptureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLogging(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          bodyLoggingOptions
+		reqBody       string
+		respBody      string
+		wantReq       string
+		wantResp      string
+		wantTruncated bool
+	}{
+		{
+			name:     "captured",
+			opts:     bodyLoggingOptions{MaxBytes: 64},
+			reqBody:  `{"name":"Temporal"}`,
+			respBody: `{"greeting":"Hello, Temporal!"}`,
+			wantReq:  `{"name":"Temporal"}`,
+			wantResp: `{"greeting":"Hello, Temporal!"}`,
+		},
+		{
+			name:          "truncated",
+			opts:          bodyLoggingOptions{MaxBytes: 8},
+			reqBody:       "0123456789abcdef",
+			respBody:      "fedcba9876543210",
+			wantReq:       "01234567",
+			wantResp:      "fedcba98",
+			wantTruncated: true,
+		},
+		{
+			name:     "redacted",
+			opts:     bodyLoggingOptions{MaxBytes: 64, Redact: redactJSONFields([]string{"password"})},
+			reqBody:  `{"username":"alice","Password":"hunter2"}`,
+			respBody: "not json",
+			wantReq:  `{"Password":"[REDACTED]","username":"alice"}`,
+			wantResp: "not json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			var handlerSaw string
+			h := adaptHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				handlerSaw = string(b)
+				io.WriteString(w, tt.respBody)
+			}),
+				withLogging(logger, accessLogLevel),
+				withBodyLogging(tt.opts),
+			)
+
+			rec := serve(h, httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(tt.reqBody)))
+
+			if handlerSaw != tt.reqBody {
+				t.Errorf("handler read %q, want the whole body %q", handlerSaw, tt.reqBody)
+			}
+			if rec.Body.String() != tt.respBody {
+				t.Errorf("client got %q, want the whole body %q", rec.Body, tt.respBody)
+			}
+			entry := findLog(t, logEntries(t, &logs), "bodies")
+			if entry["request_body"] != tt.wantReq || entry["response_body"] != tt.wantResp {
+				t.Errorf("logged bodies %q and %q, want %q and %q", entry["request_body"], entry["response_body"], tt.wantReq, tt.wantResp)
+			}
+			if entry["request_body_truncated"] != tt.wantTruncated || entry["response_body_truncated"] != tt.wantTruncated {
+				t.Errorf("truncated = %v and %v, want %v", entry["request_body_truncated"], entry["response_body_truncated"], tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestBodyLoggingNeedsDebug(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	h := adaptHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}),
+		withLogging(logger, accessLogLevel),
+		withBodyLogging(bodyLoggingOptions{MaxBytes: 64}),
+	)
+
+	rec := serve(h, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("secret body")))
+	if rec.Body.String() != "secret body" {
+		t.Errorf("body = %q", rec.Body)
+	}
+	if strings.Contains(logs.String(), "secret body") {
+		t.Errorf("body logged below debug: %s", logs.String())
+	}
+}
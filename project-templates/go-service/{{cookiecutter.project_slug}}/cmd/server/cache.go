@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bounds on what withCache keeps in memory. Each withCache instance, one
+// per route, holds at most cacheMaxBytes of bodies.
+const (
+	cacheMaxEntries   = 1000
+	cacheMaxBodyBytes = 1 << 20
+	cacheMaxBytes     = 32 << 20
+)
+
+// withCache serves repeated GET requests from memory for ttl. See
+// responseCache for what is cached and how it's keyed. Add it last in a
+// route's chain (stageCache), so only requests that pass authentication and
+// the other checks reach the cache.
+func withCache(ttl time.Duration) adapter {
+	return newResponseCache(ttl, cacheMaxEntries, cacheMaxBytes).wrap
+}
+
+// responseCache is a server-side cache of 200 responses to GET requests,
+// keyed on the path and query, for read-heavy endpoints that can serve
+// data up to a TTL old. It keeps the most recently used entries, up to
+// maxEntries of them and maxBytes of bodies in total. On
+// authenticated routes the subject from the request's claims is part of
+// the key, so one caller's response is never served to another. Responses
+// that depend on request headers must name them in Vary: a cached response
+// is only served to requests with the same values for them. Each URL keeps
+// one variant, the latest. Responses with Vary: *, Set-Cookie,
+// Cache-Control no-store or private, or bodies over cacheMaxBodyBytes
+// aren't cached. Responses carry X-Cache: HIT or MISS.
+type responseCache struct {
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+	size    int // total body bytes in entries
+}
+
+type cacheEntry struct {
+	url     string
+	vary    []string // request header names from the response's Vary
+	variant string   // the subject and values of vary in the request that filled the entry
+	expires time.Time
+	header  http.Header
+	body    []byte
+}
+
+func newResponseCache(ttl time.Duration, maxEntries, maxBytes int) *responseCache {
+	return &responseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		now:        time.Now,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+func (c *responseCache) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		url := r.URL.RequestURI()
+		if e := c.get(url, r); e != nil {
+			dst := w.Header()
+			for k, vv := range e.header.Clone() {
+				dst[k] = vv
+			}
+			dst.Set("X-Cache", "HIT")
+			w.WriteHeader(http.StatusOK)
+			w.Write(e.body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		rec := &cacheRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.cacheable() {
+			c.put(url, r, rec)
+		}
+	})
+}
+
+// get returns the fresh entry for url matching r's Vary headers, if any.
+func (c *responseCache) get(url string, r *http.Request) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[url]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	if variantKey(r, e.vary) != e.variant {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return e
+}
+
+func (c *responseCache) put(url string, r *http.Request, rec *cacheRecorder) {
+	if rec.body.Len() > c.maxBytes {
+		return
+	}
+	header := rec.Header().Clone()
+	header.Del("X-Cache")
+	vary := varyNames(header)
+	e := &cacheEntry{
+		url:     url,
+		vary:    vary,
+		variant: variantKey(r, vary),
+		expires: c.now().Add(c.ttl),
+		header:  header,
+		body:    bytes.Clone(rec.body.Bytes()),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[url]; ok {
+		c.remove(el)
+	}
+	c.entries[url] = c.lru.PushFront(e)
+	c.size += len(e.body)
+	for c.lru.Len() > c.maxEntries || c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops el from the cache. c.mu must be held.
+func (c *responseCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.url)
+	c.size -= len(e.body)
+}
+
+// varyNames returns the request header names listed in header's Vary
+// fields, canonicalized.
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// variantKey joins the subject from r's claims, if any, and r's values for
+// the headers in names, so two requests get the same variant only if they
+// come from the same caller and agree on all of them.
+func variantKey(r *http.Request, names []string) string {
+	var b strings.Builder
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		b.WriteString(claims.Subject)
+	}
+	b.WriteByte(0)
+	for _, name := range names {
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// cacheRecorder passes a response through to the client while keeping a
+// copy of its body for the cache.
+type cacheRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+	tooLarge   bool
+	flushed    bool
+}
+
+func (rec *cacheRecorder) WriteHeader(code int) {
+	rec.statusCode = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *cacheRecorder) Write(b []byte) (int, error) {
+	if !rec.tooLarge {
+		if rec.body.Len()+len(b) > cacheMaxBodyBytes {
+			rec.tooLarge = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush passes through; a flushed response is a stream and isn't cached.
+func (rec *cacheRecorder) Flush() {
+	rec.flushed = true
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *cacheRecorder) cacheable() bool {
+	if rec.statusCode != http.StatusOK || rec.tooLarge || rec.flushed {
+		return false
+	}
+	h := rec.Header()
+	if h.Get("Set-Cookie") != "" || strings.Contains(h.Get("Vary"), "*") {
+		return false
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
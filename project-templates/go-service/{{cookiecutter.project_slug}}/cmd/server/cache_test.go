@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// countingHandler answers with the number of times it has been called, so
+// tests can tell cached responses from fresh ones.
+func countingHandler(status int, header http.Header) (http.Handler, *int) {
+	calls := new(int)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "call %d", *calls)
+	}), calls
+}
+
+func TestCacheHit(t *testing.T) {
+	h, calls := countingHandler(http.StatusOK, http.Header{"Content-Type": {"text/plain"}})
+	cached := withCache(time.Minute)(h)
+
+	first := serve(cached, httptest.NewRequest(http.MethodGet, "/items?page=1", nil))
+	second := serve(cached, httptest.NewRequest(http.MethodGet, "/items?page=1", nil))
+	other := serve(cached, httptest.NewRequest(http.MethodGet, "/items?page=2", nil))
+
+	if first.Header().Get("X-Cache") != "MISS" || first.Body.String() != "call 1" {
+		t.Errorf("first: X-Cache %q body %q, want MISS and call 1", first.Header().Get("X-Cache"), first.Body)
+	}
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != "call 1" || second.Code != http.StatusOK {
+		t.Errorf("second: %d X-Cache %q body %q, want a 200 HIT with call 1", second.Code, second.Header().Get("X-Cache"), second.Body)
+	}
+	if second.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("cached Content-Type = %q, want text/plain", second.Header().Get("Content-Type"))
+	}
+	if other.Body.String() != "call 2" || *calls != 2 {
+		t.Errorf("other query: body %q after %d calls, want call 2 after 2", other.Body, *calls)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	h, calls := countingHandler(http.StatusOK, nil)
+	c := newResponseCache(time.Minute, cacheMaxEntries, cacheMaxBytes)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	cached := c.wrap(h)
+
+	serve(cached, httptest.NewRequest(http.MethodGet, "/items", nil))
+	now = now.Add(59 * time.Second)
+	if rec := serve(cached, httptest.NewRequest(http.MethodGet, "/items", nil)); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("before the TTL: X-Cache %q, want HIT", rec.Header().Get("X-Cache"))
+	}
+	now = now.Add(time.Second)
+	rec := serve(cached, httptest.NewRequest(http.MethodGet, "/items", nil))
+	if rec.Header().Get("X-Cache") != "MISS" || rec.Body.String() != "call 2" || *calls != 2 {
+		t.Errorf("at the TTL: X-Cache %q body %q after %d calls, want a fresh MISS", rec.Header().Get("X-Cache"), rec.Body, *calls)
+	}
+}
+
+func TestCacheSkips(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		status int
+		header http.Header
+	}{
+		{name: "post", method: http.MethodPost, status: http.StatusOK},
+		{name: "head", method: http.MethodHead, status: http.StatusOK},
+		{name: "not found", method: http.MethodGet, status: http.StatusNotFound},
+		{name: "server error", method: http.MethodGet, status: http.StatusInternalServerError},
+		{name: "set-cookie", method: http.MethodGet, status: http.StatusOK, header: http.Header{"Set-Cookie": {"session=1"}}},
+		{name: "no-store", method: http.MethodGet, status: http.StatusOK, header: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "vary star", method: http.MethodGet, status: http.StatusOK, header: http.Header{"Vary": {"*"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, calls := countingHandler(tt.status, tt.header)
+			cached := withCache(time.Minute)(h)
+			serve(cached, httptest.NewRequest(tt.method, "/items", nil))
+			rec := serve(cached, httptest.NewRequest(tt.method, "/items", nil))
+			if *calls != 2 || rec.Header().Get("X-Cache") == "HIT" {
+				t.Errorf("handler called %d times, X-Cache %q; want 2 calls and no HIT", *calls, rec.Header().Get("X-Cache"))
+			}
+		})
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	h, calls := countingHandler(http.StatusOK, http.Header{"Vary": {"Authorization"}})
+	cached := withCache(time.Minute)(h)
+	get := func(auth string) string {
+		req := httptest.NewRequest(http.MethodGet, "/v1/whoami", nil)
+		req.Header.Set("Authorization", auth)
+		body, _ := io.ReadAll(serve(cached, req).Body)
+		return string(body)
+	}
+
+	alice := get("Bearer alice")
+	if got := get("Bearer bob"); got == alice {
+		t.Errorf("bob got alice's cached response %q", got)
+	}
+	if got := get("Bearer bob"); got != "call 2" || *calls != 2 {
+		t.Errorf("bob again: %q after %d calls, want the cached call 2", got, *calls)
+	}
+}
+
+func TestCacheKeyedOnSubject(t *testing.T) {
+	// No Vary: the handler forgot that its response depends on the caller.
+	h, calls := countingHandler(http.StatusOK, nil)
+	cached := withCache(time.Minute)(h)
+	get := func(subject string) string {
+		req := httptest.NewRequest(http.MethodGet, "/v1/whoami", nil)
+		req = req.WithContext(context.WithValue(req.Context(), claimsKey, newClaims(jwt.MapClaims{"sub": subject})))
+		return serve(cached, req).Body.String()
+	}
+
+	alice := get("alice")
+	if got := get("bob"); got == alice {
+		t.Errorf("bob got alice's cached response %q", got)
+	}
+	if got := get("bob"); got != "call 2" || *calls != 2 {
+		t.Errorf("bob again: %q after %d calls, want the cached call 2", got, *calls)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	h, calls := countingHandler(http.StatusOK, nil)
+	cached := newResponseCache(time.Minute, 2, cacheMaxBytes).wrap(h)
+	get := func(path string) { serve(cached, httptest.NewRequest(http.MethodGet, path, nil)) }
+
+	get("/a")
+	get("/b")
+	get("/a") // hit; /b is now least recently used
+	get("/c") // evicts /b
+	get("/a")
+	if *calls != 3 {
+		t.Fatalf("handler called %d times, want 3", *calls)
+	}
+	get("/b")
+	if *calls != 4 {
+		t.Errorf("handler called %d times, want /b fetched again after eviction", *calls)
+	}
+}
+
+func TestCacheEvictsBySize(t *testing.T) {
+	h, calls := countingHandler(http.StatusOK, nil)
+	// Bodies are "call N", 6 bytes: room for two.
+	cached := newResponseCache(time.Minute, cacheMaxEntries, 12).wrap(h)
+	get := func(path string) string {
+		return serve(cached, httptest.NewRequest(http.MethodGet, path, nil)).Header().Get("X-Cache")
+	}
+
+	get("/a")
+	get("/b")
+	get("/c") // 18 bytes: evicts /a, the least recently used
+	if got := get("/b"); got != "HIT" {
+		t.Errorf("/b X-Cache = %q, want HIT", got)
+	}
+	if got := get("/c"); got != "HIT" {
+		t.Errorf("/c X-Cache = %q, want HIT", got)
+	}
+	if got := get("/a"); got != "MISS" {
+		t.Errorf("/a X-Cache = %q, want MISS after eviction", got)
+	}
+	if *calls != 4 {
+		t.Errorf("handler called %d times, want 4", *calls)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// stage names where an adapter belongs in a MiddlewareChain. Stages run
+// outermost first in the order declared here: request IDs before anything
+// that logs, recovery around everything it can cover, authentication
+// before the rate limiter (which keys on the subject) and authorization,
+// and request checks such as the content type after them, so unauthorized
+// clients get a 401 rather than a hint about the body they should send. The
+// response cache is innermost, so only requests that pass every check are
+// served from it.
+type stage int
+
+const (
+	stageRequestID stage = iota
+	stageRecovery
+	stageTracing
+	stageLogging
+	stageMetrics
+	stageDisconnect
+	stageTimeout
+	stageAuth
+	stageRateLimit
+	stageAuthz
+	stageContentType
+	stageCache
+)
+
+var stageNames = [...]string{
+	stageRequestID:   "request-id",
+	stageRecovery:    "recovery",
+	stageTracing:     "tracing",
+	stageLogging:     "logging",
+	stageMetrics:     "metrics",
+	stageDisconnect:  "disconnect",
+	stageTimeout:     "timeout",
+	stageAuth:        "auth",
+	stageRateLimit:   "rate-limit",
+	stageAuthz:       "authz",
+	stageContentType: "content-type",
+	stageCache:       "cache",
+}
+
+func (s stage) String() string {
+	if s < 0 || int(s) >= len(stageNames) {
+		return fmt.Sprintf("stage(%d)", int(s))
+	}
+	return stageNames[s]
+}
+
+// MiddlewareChain builds an adapter chain whose order is checked: adapters
+// must be added in stage order, so e.g. withRequireScope (stageAuthz) can't
+// end up outside withJWTAuth (stageAuth). Several adapters may share a
+// stage. A chain is a value; Use returns a copy, so a base chain can be
+// extended per route.
+type MiddlewareChain struct {
+	stages   []stage
+	adapters []adapter
+	err      error
+}
+
+// Use returns c with a appended at stage s. Adding a stage that must run
+// outside one already in the chain records an error, reported by Err and
+// Then.
+func (c MiddlewareChain) Use(s stage, a adapter) MiddlewareChain {
+	if c.err == nil && len(c.stages) > 0 {
+		if last := c.stages[len(c.stages)-1]; s < last {
+			c.err = fmt.Errorf("middleware stage %s must come before %s", s, last)
+		}
+	}
+	// Copy so chains sharing a prefix don't share a backing array.
+	c.stages = append(c.stages[:len(c.stages):len(c.stages)], s)
+	c.adapters = append(c.adapters[:len(c.adapters):len(c.adapters)], a)
+	return c
+}
+
+// Err reports the first ordering mistake made while building c.
+func (c MiddlewareChain) Err() error {
+	return c.err
+}
+
+// Stages returns the chain's stages, outermost first.
+func (c MiddlewareChain) Stages() []stage {
+	return append([]stage(nil), c.stages...)
+}
+
+// Adapters returns the chain's adapters, outermost first, for APIs that
+// take them directly such as registerPprof. It panics if c is misordered.
+func (c MiddlewareChain) Adapters() []adapter {
+	if c.err != nil {
+		panic(c.err)
+	}
+	return append([]adapter(nil), c.adapters...)
+}
+
+// Then wraps h in the chain. Chains are built once at startup, so a
+// misordered one is a programming error and Then panics; TestBuildRouter
+// catches it before deployment.
+func (c MiddlewareChain) Then(h http.Handler) http.Handler {
+	return adaptHandler(h, c.Adapters()...)
+}
+
+// DefaultChain returns the chain most routes start from: request ID, panic
+// recovery, tracing and access logging.
+func DefaultChain(logger *slog.Logger) MiddlewareChain {
+	return MiddlewareChain{}.
+		Use(stageRequestID, withRequestID(uuid.NewString)).
+		Use(stageRecovery, withRecovery(logger)).
+		Use(stageTracing, withTracing()).
+		Use(stageLogging, withLogging(logger, accessLogLevel))
+}
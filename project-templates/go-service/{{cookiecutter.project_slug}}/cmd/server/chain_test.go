@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordAdapter appends name to order when a request passes through it.
+func recordAdapter(order *[]string, name string) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*order = append(*order, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestMiddlewareChainOrder(t *testing.T) {
+	var order []string
+	chain := MiddlewareChain{}.
+		Use(stageRequestID, recordAdapter(&order, "request-id")).
+		Use(stageRecovery, recordAdapter(&order, "recovery")).
+		Use(stageAuth, recordAdapter(&order, "auth")).
+		Use(stageAuthz, recordAdapter(&order, "scope:read")).
+		Use(stageAuthz, recordAdapter(&order, "scope:write"))
+	if err := chain.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	h := chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"request-id", "recovery", "auth", "scope:read", "scope:write", "handler"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	wantStages := []stage{stageRequestID, stageRecovery, stageAuth, stageAuthz, stageAuthz}
+	if got := chain.Stages(); !reflect.DeepEqual(got, wantStages) {
+		t.Errorf("Stages() = %v, want %v", got, wantStages)
+	}
+}
+
+func TestMiddlewareChainRejectsMisorder(t *testing.T) {
+	chain := DefaultChain(discardLogger).
+		Use(stageAuthz, withRequireScope("admin")).
+		Use(stageAuth, withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}))
+
+	err := chain.Err()
+	if err == nil || !strings.Contains(err.Error(), "auth must come before authz") {
+		t.Fatalf("Err() = %v, want auth before authz", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Then did not panic on a misordered chain")
+		}
+	}()
+	chain.Then(okHandler)
+}
+
+func TestMiddlewareChainBranchesIndependently(t *testing.T) {
+	var order []string
+	base := MiddlewareChain{}.Use(stageRequestID, recordAdapter(&order, "request-id"))
+	a := base.Use(stageAuth, recordAdapter(&order, "a"))
+	b := base.Use(stageAuth, recordAdapter(&order, "b"))
+
+	a.Then(okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	b.Then(okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"request-id", "a", "request-id", "b"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims is the typed view of a verified JWT that withJWTAuth stores in the
+// request context. Raw keeps every claim, so custom fields remain available.
+type Claims struct {
+	Subject   string
+	Email     string
+	Issuer    string
+	Audience  []string
+	Scopes    []string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	Raw       jwt.MapClaims
+}
+
+// ClaimsFromContext returns the claims placed in ctx by withJWTAuth.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+func newClaims(raw jwt.MapClaims) *Claims {
+	c := &Claims{Raw: raw}
+	c.Subject, _ = raw.GetSubject()
+	c.Issuer, _ = raw.GetIssuer()
+	c.Audience, _ = raw.GetAudience()
+	if exp, _ := raw.GetExpirationTime(); exp != nil {
+		c.ExpiresAt = exp.Time
+	}
+	if iat, _ := raw.GetIssuedAt(); iat != nil {
+		c.IssuedAt = iat.Time
+	}
+	c.Email, _ = raw["email"].(string)
+	c.Scopes = claimScopes(raw)
+	return c
+}
+
+// HasScope reports whether the claims grant any of the given scopes.
+func (c *Claims) HasScope(scopes ...string) bool {
+	for _, want := range scopes {
+		for _, have := range c.Scopes {
+			if want == have {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimScopes collects the scope and roles claims into a single list.
+// "roles" may be a single string or an array.
+func claimScopes(claims jwt.MapClaims) []string {
+	var scopes []string
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+	switch roles := claims["roles"].(type) {
+	case string:
+		scopes = append(scopes, roles)
+	case []interface{}:
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
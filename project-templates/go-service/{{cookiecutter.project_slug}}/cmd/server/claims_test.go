@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestNewClaims(t *testing.T) {
+	exp := time.Unix(1700003600, 0)
+	iat := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name string
+		raw  jwt.MapClaims
+		want Claims
+	}{
+		{
+			name: "all claims",
+			// Numbers as encoding/json decodes them, like the parser does.
+			raw: jwt.MapClaims{
+				"sub":   "u1",
+				"email": "u1@example.com",
+				"iss":   "https://idp.example",
+				"aud":   []interface{}{"api", "web"},
+				"scope": "read write",
+				"roles": []interface{}{"admin"},
+				"exp":   float64(exp.Unix()),
+				"iat":   float64(iat.Unix()),
+			},
+			want: Claims{
+				Subject:   "u1",
+				Email:     "u1@example.com",
+				Issuer:    "https://idp.example",
+				Audience:  []string{"api", "web"},
+				Scopes:    []string{"read", "write", "admin"},
+				ExpiresAt: exp,
+				IssuedAt:  iat,
+			},
+		},
+		{
+			name: "single audience and role",
+			raw:  jwt.MapClaims{"sub": "u1", "aud": "api", "roles": "admin"},
+			want: Claims{Subject: "u1", Audience: []string{"api"}, Scopes: []string{"admin"}},
+		},
+		{
+			name: "missing exp and iat",
+			raw:  jwt.MapClaims{"sub": "u1"},
+			want: Claims{Subject: "u1"},
+		},
+		{
+			name: "wrong types ignored",
+			raw:  jwt.MapClaims{"sub": "u1", "email": 42, "exp": "tomorrow", "iat": true},
+			want: Claims{Subject: "u1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newClaims(tt.raw)
+			if !reflect.DeepEqual(got.Raw, tt.raw) {
+				t.Errorf("Raw = %v, want %v", got.Raw, tt.raw)
+			}
+			got.Raw = nil
+			if !got.ExpiresAt.Equal(tt.want.ExpiresAt) || !got.IssuedAt.Equal(tt.want.IssuedAt) {
+				t.Errorf("ExpiresAt, IssuedAt = %v, %v, want %v, %v", got.ExpiresAt, got.IssuedAt, tt.want.ExpiresAt, tt.want.IssuedAt)
+			}
+			got.ExpiresAt, got.IssuedAt = time.Time{}, time.Time{}
+			tt.want.ExpiresAt, tt.want.IssuedAt = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("newClaims = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	if _, ok := ClaimsFromContext(context.Background()); ok {
+		t.Error("ClaimsFromContext reported claims in an empty context")
+	}
+
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	var got *Claims
+	h := withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ClaimsFromContext(r.Context())
+	}))
+	serve(h, authedRequest(t, http.MethodGet, "/", jwt.MapClaims{
+		"sub":   "u1",
+		"email": "u1@example.com",
+		"iss":   "https://idp.example",
+		"aud":   "api",
+		"exp":   exp.Unix(),
+	}))
+
+	if got == nil {
+		t.Fatal("no claims in the request context")
+	}
+	if got.Subject != "u1" || got.Email != "u1@example.com" || got.Issuer != "https://idp.example" {
+		t.Errorf("claims = %+v, want sub u1, email u1@example.com and iss https://idp.example", got)
+	}
+	if !reflect.DeepEqual(got.Audience, []string{"api"}) {
+		t.Errorf("Audience = %v, want [api]", got.Audience)
+	}
+	if !got.ExpiresAt.Equal(exp) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, exp)
+	}
+	if !got.IssuedAt.IsZero() {
+		t.Errorf("IssuedAt = %v, want zero without an iat claim", got.IssuedAt)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const clientIPKey contextKey = "client_ip"
+
+// withClientIP resolves the client's address once per request for
+// clientIP. trusted lists the proxies whose forwarding headers are
+// believed; with none, X-Forwarded-For and X-Real-IP are ignored, since any
+// client can send them. Place it outermost so every later middleware sees
+// the same address.
+func withClientIP(trusted []netip.Prefix) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip, ok := resolveClientIP(r, trusted); ok {
+				r = r.WithContext(context.WithValue(r.Context(), clientIPKey, ip))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address resolved by withClientIP, or the host part
+// of r.RemoteAddr when it didn't run or couldn't parse the peer.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(netip.Addr); ok {
+		return ip.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// resolveClientIP returns the address of the client that sent r. When the
+// direct peer is a trusted proxy, X-Forwarded-For is walked from the right
+// and the first hop that isn't a trusted proxy wins: entries to its left
+// were supplied by the client and can't be believed. X-Real-IP is used
+// only when a trusted peer sent no X-Forwarded-For.
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	ip := peer.Unmap()
+	if !prefixesContain(trusted, ip) {
+		return ip, true
+	}
+
+	xff := r.Header.Values("X-Forwarded-For")
+	if len(xff) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return realIP.Unmap(), true
+		}
+		return ip, true
+	}
+
+	hops := strings.Split(strings.Join(xff, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry means the chain can't be trusted past this
+			// point; fall back to the last hop we could verify.
+			break
+		}
+		ip = hop.Unmap()
+		if !prefixesContain(trusted, ip) {
+			break
+		}
+	}
+	return ip, true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := mustPrefixes(t, "192.0.2.0/24", "198.51.100.7")
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{name: "direct", trusted: true, remoteAddr: "203.0.113.9:1234", want: "203.0.113.9"},
+		{name: "direct IPv6", trusted: true, remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{
+			name:       "headers ignored without trusted proxies",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.9"}, "X-Real-Ip": {"203.0.113.9"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "headers ignored from untrusted peer",
+			trusted:    true,
+			remoteAddr: "203.0.113.9:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.1"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "single hop",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.9"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "multi-hop through trusted proxies",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.1, 203.0.113.9, 198.51.100.7"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "multiple headers",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.1", "203.0.113.9"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "all hops trusted",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.2, 198.51.100.7"}},
+			want:       "192.0.2.2",
+		},
+		{
+			name:       "malformed hop stops the walk",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.9, garbage, 198.51.100.7"}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "X-Real-IP from trusted proxy",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Real-Ip": {"203.0.113.9"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "X-Forwarded-For preferred over X-Real-IP",
+			trusted:    true,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"203.0.113.9"}, "X-Real-Ip": {"10.0.0.1"}},
+			want:       "203.0.113.9",
+		},
+		{name: "unparseable peer", trusted: true, remoteAddr: "pipe", want: "pipe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, vv := range tt.headers {
+				for _, v := range vv {
+					req.Header.Add(k, v)
+				}
+			}
+			var proxies []netip.Prefix
+			if tt.trusted {
+				proxies = trusted
+			}
+
+			var got string
+			withClientIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
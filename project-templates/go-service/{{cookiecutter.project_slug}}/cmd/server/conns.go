@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// connTracker counts the server's client connections as an
+// http.Server.ConnState hook, and closes new ones past a limit so a flood
+// of connections can't exhaust file descriptors or memory.
+type connTracker struct {
+	max      int64 // 0 for no limit
+	count    atomic.Int64
+	active   prometheus.Gauge
+	rejected prometheus.Counter
+}
+
+// newConnTracker registers http_connections_active and
+// http_connections_rejected_total with reg. max of 0 disables the limit.
+func newConnTracker(reg prometheus.Registerer, max int) *connTracker {
+	t := &connTracker{
+		max: int64(max),
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_connections_active",
+			Help: "Open client connections, idle ones included.",
+		}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_connections_rejected_total",
+			Help: "Client connections closed on accept because max-conns was reached.",
+		}),
+	}
+	reg.MustRegister(t.active, t.rejected)
+	return t
+}
+
+// ConnState is the http.Server.ConnState hook. Rejected connections are
+// counted until their close is reported, like any other, so the count
+// can't drift.
+func (t *connTracker) ConnState(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		t.active.Inc()
+		if n := t.count.Add(1); t.max > 0 && n > t.max {
+			t.rejected.Inc()
+			c.Close()
+		}
+	case http.StateHijacked, http.StateClosed:
+		t.active.Dec()
+		t.count.Add(-1)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricValue returns the value of the unlabelled gauge or counter name.
+func metricValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		m := f.GetMetric()[0]
+		if g := m.GetGauge(); g != nil {
+			return g.GetValue()
+		}
+		return m.GetCounter().GetValue()
+	}
+	t.Fatalf("no metric %s", name)
+	return 0
+}
+
+// waitForMetric polls until name reaches want, as ConnState hooks run on
+// the server's goroutines.
+func waitForMetric(t *testing.T, reg *prometheus.Registry, name string, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for metricValue(t, reg, name) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %v, want %v", name, metricValue(t, reg, name), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConnTrackerGauge(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	srv := httptest.NewUnstartedServer(okHandler)
+	srv.Config.ConnState = newConnTracker(reg, 0).ConnState
+	srv.Start()
+	defer srv.Close()
+
+	var conns []net.Conn
+	for range 3 {
+		c, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		conns = append(conns, c)
+	}
+	waitForMetric(t, reg, "http_connections_active", 3)
+
+	conns[0].Close()
+	waitForMetric(t, reg, "http_connections_active", 2)
+}
+
+func TestConnTrackerLimit(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	srv := httptest.NewUnstartedServer(okHandler)
+	srv.Config.ConnState = newConnTracker(reg, 1).ConnState
+	srv.Start()
+	defer srv.Close()
+
+	first, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	waitForMetric(t, reg, "http_connections_active", 1)
+
+	second, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("reading from the connection past the limit: %v, want EOF", err)
+	}
+	waitForMetric(t, reg, "http_connections_rejected_total", 1)
+	waitForMetric(t, reg, "http_connections_active", 1)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsOptions configures withCORS. Origins that match an entry exactly are
+// echoed back and may send credentials (cookies, Authorization). A "*"
+// entry lets any other origin make uncredentialed requests: it is answered
+// with a literal "*", which browsers never combine with credentials, so
+// arbitrary sites can't make authenticated reads.
+type corsOptions struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         time.Duration
+}
+
+func defaultCORSOptions(origins []string) corsOptions {
+	return corsOptions{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// withCORS must wrap the whole mux rather than individual routes: preflight
+// requests use OPTIONS, which never match the method-prefixed route patterns.
+func withCORS(opts corsOptions) adapter {
+	allowed := make(map[string]bool, len(opts.AllowedOrigins))
+	for _, o := range opts.AllowedOrigins {
+		allowed[o] = true
+	}
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			exact := allowed[origin]
+			if !exact && !allowed["*"] {
+				if preflight {
+					writeProblem(w, r, problemForbidden("origin not allowed"))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if exact {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
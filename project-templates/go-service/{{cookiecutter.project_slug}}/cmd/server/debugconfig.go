@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// handleDebugConfig serves the effective configuration as JSON, with the
+// same fields and masking as the startup log line (serverConfig.LogValue),
+// so operators can check what a pod is running without shell access. The
+// log level is read from level on each request, so it reflects SIGHUP
+// reloads and SIGUSR1 cycling; a nil level reports the startup value.
+func handleDebugConfig(cfg serverConfig, level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := cfg
+		if level != nil {
+			cfg.Log.Level = strings.ToLower(level.Level().String())
+		}
+		writeJSON(w, logValueJSON(cfg.LogValue()), http.StatusOK)
+	})
+}
+
+// logValueJSON converts v for encoding/json: groups become objects and
+// durations strings such as "30s".
+func logValueJSON(v slog.Value) any {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		obj := map[string]any{}
+		for _, a := range v.Group() {
+			obj[a.Key] = logValueJSON(a.Value)
+		}
+		return obj
+	case slog.KindDuration:
+		return v.Duration().String()
+	default:
+		return v.Any()
+	}
+}
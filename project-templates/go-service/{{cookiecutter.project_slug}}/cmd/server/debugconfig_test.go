@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDebugConfig(t *testing.T) {
+	h := testRouter(t, func(cfg *serverConfig) {
+		cfg.Addr = ":9090"
+		cfg.JWTSecret = "jwt-s3cret"
+		cfg.MetricsAuthToken = "metrics-s3cret"
+		cfg.OAuth = oauthClientOptions{TokenURL: "https://idp.example/token", ClientID: "svc", ClientSecret: "oauth-s3cret"}
+	})
+
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		wantStatus int
+	}{
+		{name: "admin", claims: jwt.MapClaims{"sub": "ops", "scope": "admin"}, wantStatus: http.StatusOK},
+		{name: "not admin", claims: jwt.MapClaims{"sub": "u1"}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, authedRequest(t, http.MethodGet, "/debug/config", tt.claims))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if strings.Contains(rec.Body.String(), "s3cret") {
+				t.Errorf("config leaks a secret: %s", rec.Body)
+			}
+			var got struct {
+				Addr     string         `json:"addr"`
+				Auth     map[string]any `json:"auth"`
+				OAuth    map[string]any `json:"oauth"`
+				Timeouts map[string]any `json:"timeouts"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Addr != ":9090" || got.OAuth["client_id"] != "svc" || got.Timeouts["request"] != "1s" {
+				t.Errorf("config = %s, want addr :9090, oauth client_id svc and a 1s request timeout", rec.Body)
+			}
+			if got.Auth["jwt_secret"] != "[REDACTED]" || got.OAuth["client_secret"] != "[REDACTED]" {
+				t.Errorf("secrets = %v, %v, want [REDACTED]", got.Auth["jwt_secret"], got.OAuth["client_secret"])
+			}
+		})
+	}
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/debug/config", nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", rec.Code)
+	}
+}
+
+func TestDebugConfigReportsCurrentLogLevel(t *testing.T) {
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelInfo)
+	h := handleDebugConfig(serverConfig{Log: logOptions{Level: "info"}}, level)
+
+	logLevel := func() string {
+		t.Helper()
+		var got struct {
+			Log map[string]any `json:"log"`
+		}
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprint(got.Log["level"])
+	}
+	if got := logLevel(); got != "info" {
+		t.Errorf("level = %q, want info", got)
+	}
+	// As after a SIGHUP reload or SIGUSR1 cycle.
+	level.Set(slog.LevelDebug)
+	if got := logLevel(); got != "debug" {
+		t.Errorf("level after change = %q, want debug", got)
+	}
+}
+
+// TestServerConfigLogValueCoversEveryField sets each leaf field of
+// serverConfig in turn and fails if /debug/config would not show the
+// change, so a new setting can't be added without being reported.
+func TestServerConfigLogValueCoversEveryField(t *testing.T) {
+	baseline := logValueJSON(serverConfig{}.LogValue())
+
+	var walk func(path string, typ reflect.Type, index []int)
+	walk = func(path string, typ reflect.Type, index []int) {
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			name, idx := path+f.Name, append(append([]int(nil), index...), i)
+			if f.Type.Kind() == reflect.Struct {
+				walk(name+".", f.Type, idx)
+				continue
+			}
+			var cfg serverConfig
+			v := reflect.ValueOf(&cfg).Elem().FieldByIndex(idx)
+			switch v.Kind() {
+			case reflect.String:
+				v.SetString("x")
+			case reflect.Bool:
+				v.SetBool(true)
+			case reflect.Int, reflect.Int64:
+				v.SetInt(1)
+			case reflect.Float64:
+				v.SetFloat(1)
+			case reflect.Slice:
+				v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+			default:
+				t.Errorf("%s: unhandled kind %s", name, v.Kind())
+				continue
+			}
+			if reflect.DeepEqual(logValueJSON(cfg.LogValue()), baseline) {
+				t.Errorf("serverConfig.%s is missing from LogValue", name)
+			}
+		}
+	}
+	walk("", reflect.TypeFor[serverConfig](), nil)
+}
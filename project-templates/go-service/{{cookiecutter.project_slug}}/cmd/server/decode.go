@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// maxDecodeBytes caps bodies read by decodeJSON. withMaxBodySize usually
+// applies a tighter limit first; this one also protects handlers mounted
+// without it.
+const maxDecodeBytes = 1 << 20
+
+// Validator is implemented by request types that check their own fields
+// after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// decodeJSON decodes r's body into a T. It requires an application/json
+// Content-Type, rejects unknown fields, trailing data and bodies over
+// maxDecodeBytes, and runs T's Validate method if it has one. Failures are
+// problems with a client-safe detail, so handlers can pass them straight to
+// writeProblem:
+//
+//	req, prob := decodeJSON[createItemRequest](r)
+//	if prob != nil {
+//		writeProblem(w, r, prob)
+//		return
+//	}
+func decodeJSON[T any](r *http.Request) (T, *problem) {
+	var v T
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return v, problemUnsupportedMediaType("content type must be application/json")
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxDecodeBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return v, decodeProblem(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return v, problemBadRequest("request body must contain a single JSON value")
+	}
+
+	if val, ok := any(&v).(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return v, problemBadRequest(err.Error())
+		}
+	}
+	return v, nil
+}
+
+// decodeProblem maps a json.Decoder error to a problem that says what was
+// wrong without echoing the decoder's internals.
+func decodeProblem(err error) *problem {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		return problemBadRequest(fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return problemBadRequest("malformed JSON")
+	case errors.Is(err, io.EOF):
+		return problemBadRequest("request body must not be empty")
+	case errors.As(err, &typeErr):
+		return problemBadRequest(fmt.Sprintf("field %q must be %s", typeErr.Field, typeErr.Type))
+	case errors.As(err, &maxErr):
+		return newProblem(http.StatusRequestEntityTooLarge, "request body too large")
+	default:
+		// DisallowUnknownFields reports `json: unknown field "name"`.
+		var field string
+		if _, scanErr := fmt.Sscanf(err.Error(), "json: unknown field %q", &field); scanErr == nil {
+			return problemBadRequest(fmt.Sprintf("unknown field %q", field))
+		}
+		return problemBadRequest("invalid request body")
+	}
+}
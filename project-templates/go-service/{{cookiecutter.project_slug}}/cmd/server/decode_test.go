@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type createItemRequest struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (c *createItemRequest) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        createItemRequest
+		wantStatus  int
+		wantDetail  string
+	}{
+		{
+			name:        "valid",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"widget","count":2}`,
+			want:        createItemRequest{Name: "widget", Count: 2},
+		},
+		{
+			name:        "wrong content type",
+			contentType: "text/plain",
+			body:        `{"name":"widget"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantDetail:  "content type must be application/json",
+		},
+		{
+			name:        "malformed",
+			contentType: "application/json",
+			body:        `{"name":}`,
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  "malformed JSON at offset 9",
+		},
+		{
+			name:        "truncated",
+			contentType: "application/json",
+			body:        `{"name":"widget"`,
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  "malformed JSON",
+		},
+		{
+			name:        "empty",
+			contentType: "application/json",
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  "request body must not be empty",
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"name":"widget","colour":"red"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  `unknown field "colour"`,
+		},
+		{
+			name:        "wrong type",
+			contentType: "application/json",
+			body:        `{"name":"widget","count":"two"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  `field "count" must be int`,
+		},
+		{
+			name:        "trailing data",
+			contentType: "application/json",
+			body:        `{"name":"widget"}{"name":"gadget"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  "request body must contain a single JSON value",
+		},
+		{
+			name:        "fails validation",
+			contentType: "application/json",
+			body:        `{"count":1}`,
+			wantStatus:  http.StatusBadRequest,
+			wantDetail:  "name is required",
+		},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"name":"` + strings.Repeat("x", maxDecodeBytes) + `"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantDetail:  "request body too large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			got, p := decodeJSON[createItemRequest](req)
+
+			if tt.wantStatus == 0 {
+				if p != nil {
+					t.Fatalf("decodeJSON: %v", p)
+				}
+				if got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("decodeJSON returned no problem")
+			}
+			if p.Status != tt.wantStatus || p.Detail != tt.wantDetail {
+				t.Errorf("problem = %d %q, want %d %q", p.Status, p.Detail, tt.wantStatus, tt.wantDetail)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// withClientDisconnect makes abandoned requests visible. net/http cancels
+// r.Context() when the client disconnects, so downstream work stops if it
+// passes the context to its I/O or checks requestAbandoned between
+// expensive steps. When a request ends cancelled with no response written,
+// this answers 499 (statusClientClosedRequest), so the logging and metrics
+// middleware outside it record a disconnect rather than a 200.
+//
+// The cancellation has limits. Over HTTP/1.x a disconnect is only noticed
+// once the handler has read the whole request body. And the server's
+// WriteTimeout cancels nothing: once it passes, writes fail silently while
+// the handler runs on, so bound handlers with withTimeout (request-timeout)
+// below write-timeout.
+func withClientDisconnect() adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			dw := &disconnectWriter{ResponseWriter: w}
+			next.ServeHTTP(dw, r)
+
+			if dw.wrote || !errors.Is(r.Context().Err(), context.Canceled) {
+				return
+			}
+			LoggerFromContext(r.Context()).InfoContext(r.Context(), "client disconnected before the response")
+			w.WriteHeader(statusClientClosedRequest)
+		})
+	}
+}
+
+// requestAbandoned reports whether the client went away or the request
+// deadline passed. Either way nobody will read the response, so a handler
+// should stop and return without writing. Check it between expensive steps
+// that don't take a context themselves:
+//
+//	for _, item := range items {
+//		if requestAbandoned(r) {
+//			return
+//		}
+//		process(item)
+//	}
+func requestAbandoned(r *http.Request) bool {
+	return r.Context().Err() != nil
+}
+
+// disconnectWriter records whether the handler started a response.
+type disconnectWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (dw *disconnectWriter) WriteHeader(code int) {
+	dw.wrote = true
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+func (dw *disconnectWriter) Write(b []byte) (int, error) {
+	dw.wrote = true
+	return dw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (dw *disconnectWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
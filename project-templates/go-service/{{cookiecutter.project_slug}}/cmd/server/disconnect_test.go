@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/report", nil).WithContext(ctx)
+
+	// The handler works through items until the client goes away after
+	// the third.
+	processed := 0
+	h := withClientDisconnect()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 10 {
+			if requestAbandoned(r) {
+				return
+			}
+			processed++
+			if processed == 3 {
+				cancel()
+			}
+		}
+		w.Write([]byte("report"))
+	}))
+	rec := serve(h, req)
+
+	if processed != 3 {
+		t.Errorf("processed %d items, want 3", processed)
+	}
+	if rec.Code != statusClientClosedRequest {
+		t.Errorf("status = %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+}
+
+func TestClientDisconnectKeepsResponse(t *testing.T) {
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		write      bool
+		wantStatus int
+	}{
+		{name: "completed", ctx: context.Background(), write: true, wantStatus: http.StatusAccepted},
+		{name: "written before disconnect", ctx: cancelled, write: true, wantStatus: http.StatusAccepted},
+		// withTimeout answers deadlines; this middleware only handles
+		// disconnects.
+		{name: "deadline", ctx: expired, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := withClientDisconnect()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.write {
+					w.WriteHeader(http.StatusAccepted)
+				}
+			}))
+			rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tt.ctx))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeJSONCacheable is writeJSON for read endpoints whose responses
+// clients and proxies may cache. It sets a weak ETag computed from the
+// encoded body and answers a GET or HEAD whose If-None-Match matches it
+// with 304 Not Modified and no body. Non-2xx codes are written as by
+// writeJSON, without an ETag.
+func writeJSONCacheable(w http.ResponseWriter, r *http.Request, data interface{}, code int) {
+	if code < 200 || code > 299 {
+		writeJSON(w, data, code)
+		return
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		writeProblem(w, r, problemInternal("failed to encode response"))
+		return
+	}
+	body = append(body, '\n') // match json.Encoder, which writeJSON uses
+
+	etag := weakETag(body)
+	w.Header().Set("ETag", etag)
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// weakETag returns a weak entity tag for body. It is weak because the same
+// data may be served with a different Content-Encoding, e.g. by withGzip.
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag
+// using the weak comparison RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSONCacheable(t *testing.T) {
+	data := map[string]string{"status": "ok"}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONCacheable(w, r, data, http.StatusOK)
+	})
+
+	first := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	if first.Code != http.StatusOK {
+		t.Fatalf("first status = %d, want 200", first.Code)
+	}
+	etag := first.Header().Get("ETag")
+	if len(etag) < 4 || etag[:3] != `W/"` {
+		t.Fatalf("ETag = %q, want a weak entity tag", etag)
+	}
+	if got := first.Body.String(); got != "{\"status\":\"ok\"}\n" {
+		t.Errorf("body = %q", got)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "matching", method: http.MethodGet, ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "matching strong form", method: http.MethodGet, ifNoneMatch: etag[2:], wantStatus: http.StatusNotModified},
+		{name: "in a list", method: http.MethodGet, ifNoneMatch: `"other", ` + etag, wantStatus: http.StatusNotModified},
+		{name: "wildcard", method: http.MethodGet, ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "head", method: http.MethodHead, ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "stale", method: http.MethodGet, ifNoneMatch: `W/"stale"`, wantStatus: http.StatusOK},
+		{name: "not a read", method: http.MethodPost, ifNoneMatch: etag, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			rec := serve(handler, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if tt.wantStatus == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 body = %q, want empty", rec.Body.String())
+			}
+		})
+	}
+}
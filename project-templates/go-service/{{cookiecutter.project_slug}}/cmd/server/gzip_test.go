@@ -0,0 +1,74 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat(`{"greeting":"hello"}`, 100)
+	srv := httptest.NewServer(withGzip()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("hints") {
+			w.Header().Set("Link", "</style.css>; rel=preload")
+			w.WriteHeader(http.StatusEarlyHints)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	})))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		encoding string
+		wantGzip bool
+	}{
+		{name: "gzip client", path: "/", encoding: "gzip", wantGzip: true},
+		{name: "identity client", path: "/", encoding: "identity"},
+		{name: "gzip refused", path: "/", encoding: "gzip;q=0"},
+		{name: "after early hints", path: "/?hints", encoding: "gzip", wantGzip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			// Setting it by hand stops the transport decompressing for us.
+			req.Header.Set("Accept-Encoding", tt.encoding)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			gotGzip := resp.Header.Get("Content-Encoding") == "gzip"
+			if gotGzip != tt.wantGzip {
+				t.Fatalf("gzipped = %v, want %v", gotGzip, tt.wantGzip)
+			}
+			r := io.Reader(resp.Body)
+			if gotGzip {
+				zr, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = zr
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != body {
+				t.Errorf("body = %.40q..., want the handler's body", got)
+			}
+			if v := resp.Header.Values("Vary"); !strings.Contains(strings.Join(v, ","), "Accept-Encoding") {
+				t.Errorf("Vary = %v, want Accept-Encoding", v)
+			}
+		})
+	}
+}
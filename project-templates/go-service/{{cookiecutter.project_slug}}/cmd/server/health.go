@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"{{cookiecutter.go_mod}}/worker"
+)
+
+// healthCheckTimeout bounds each check so a hung dependency can't outlast
+// the probe's own timeout.
+const healthCheckTimeout = 3 * time.Second
+
+// HealthChecker reports whether a dependency (database, cache, Temporal...)
+// is usable.
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// temporalHealthChecker verifies the Temporal frontend is reachable over the
+// shared client.
+type temporalHealthChecker struct {
+	clients *worker.ClientProvider
+}
+
+func (t temporalHealthChecker) Name() string { return "temporal" }
+
+func (t temporalHealthChecker) Check(ctx context.Context) error {
+	return worker.CheckConnection(ctx, t.clients)
+}
+
+// healthRegistry holds the checks the health endpoints run. Register checks
+// at startup.
+type healthRegistry struct {
+	mu       sync.RWMutex
+	checkers []HealthChecker
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{}
+}
+
+func (reg *healthRegistry) Register(checkers ...HealthChecker) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.checkers = append(reg.checkers, checkers...)
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Run executes all checks concurrently and returns each one's result keyed
+// by name, and whether all of them passed.
+func (reg *healthRegistry) Run(ctx context.Context) (map[string]checkResult, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	results := make(map[string]checkResult, len(reg.checkers))
+	healthy := true
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, checker := range reg.checkers {
+		wg.Add(1)
+		go func(checker HealthChecker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			result := checkResult{Status: "ok"}
+			if err := checker.Check(ctx); err != nil {
+				result = checkResult{Status: "error", Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[checker.Name()] = result
+			if result.Status != "ok" {
+				healthy = false
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	return results, healthy
+}
+
+// handleLive reports that the process is up. It never checks dependencies:
+// a failing liveness probe restarts the pod, which won't fix a database.
+func handleLive() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"status": "ok"}, http.StatusOK)
+	})
+}
+
+// markNotReady flips shuttingDown, so /healthz and /readyz start returning
+// 503, then waits delay before returning. The wait gives load balancers time
+// to see the failing probe and stop sending new requests while the listener
+// is still open; in-flight requests are drained by server.Shutdown after.
+// It returns early if ctx is done, so an impatient operator isn't kept
+// waiting out the delay.
+func markNotReady(ctx context.Context, shuttingDown *atomic.Bool, delay time.Duration) {
+	shuttingDown.Store(true)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// handleHealth runs the registered checks and returns the aggregated result,
+// with 503 if any check fails or the server is shutting down. It serves
+// unauthenticated probes, so failures are logged and each check reports
+// only its status; handleHealthDetails shows the errors.
+func handleHealth(reg *healthRegistry, shuttingDown *atomic.Bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			writeJSON(w, map[string]string{"status": "shutting down"}, http.StatusServiceUnavailable)
+			return
+		}
+
+		checks, healthy := reg.Run(r.Context())
+		for name, result := range checks {
+			if result.Error != "" {
+				LoggerFromContext(r.Context()).WarnContext(r.Context(), "health check failed", "check", name, "error", result.Error)
+				checks[name] = checkResult{Status: result.Status}
+			}
+		}
+		writeHealth(w, checks, healthy)
+	})
+}
+
+// handleHealthDetails is handleHealth with each failing check's error, for
+// operators. Errors name internal hosts and namespaces, so serve it behind
+// authentication.
+func handleHealthDetails(reg *healthRegistry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks, healthy := reg.Run(r.Context())
+		writeHealth(w, checks, healthy)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, checks map[string]checkResult, healthy bool) {
+	status, code := "ok", http.StatusOK
+	if !healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSON(w, map[string]interface{}{"status": status, "checks": checks}, code)
+}
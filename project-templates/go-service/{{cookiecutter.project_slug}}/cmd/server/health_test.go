@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeChecker is a HealthChecker that returns err.
+type fakeChecker struct {
+	name string
+	err  error
+}
+
+func (f fakeChecker) Name() string                    { return f.name }
+func (f fakeChecker) Check(ctx context.Context) error { return f.err }
+
+func TestHealthRegistry(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkers   []HealthChecker
+		wantStatus int
+		wantBody   map[string]interface{}
+	}{
+		{
+			name:       "no checks",
+			wantStatus: http.StatusOK,
+			wantBody:   map[string]interface{}{"status": "ok", "checks": map[string]interface{}{}},
+		},
+		{
+			name:       "all passing",
+			checkers:   []HealthChecker{fakeChecker{name: "db"}, fakeChecker{name: "cache"}},
+			wantStatus: http.StatusOK,
+			wantBody: map[string]interface{}{"status": "ok", "checks": map[string]interface{}{
+				"db":    map[string]interface{}{"status": "ok"},
+				"cache": map[string]interface{}{"status": "ok"},
+			}},
+		},
+		{
+			name:       "one failing",
+			checkers:   []HealthChecker{fakeChecker{name: "db"}, fakeChecker{name: "cache", err: errors.New("connection refused")}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody: map[string]interface{}{"status": "unavailable", "checks": map[string]interface{}{
+				"db":    map[string]interface{}{"status": "ok"},
+				"cache": map[string]interface{}{"status": "error"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newHealthRegistry()
+			reg.Register(tt.checkers...)
+			rec := serve(handleHealth(reg, new(atomic.Bool)), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.wantBody) {
+				t.Errorf("body = %v, want %v", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLiveIgnoresFailingChecks(t *testing.T) {
+	reg := newHealthRegistry()
+	reg.Register(fakeChecker{name: "db", err: errors.New("connection refused")})
+	h := buildRouter(routerDeps{
+		cfg:          serverConfig{RequestTimeout: time.Second, LatencyBuckets: prometheus.DefBuckets},
+		logger:       discardLogger,
+		registry:     prometheus.NewRegistry(),
+		health:       reg,
+		shuttingDown: new(atomic.Bool),
+	})
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/livez", nil)); rec.Code != http.StatusOK {
+		t.Errorf("/livez status = %d, want 200 while a check fails", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/readyz", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz status = %d, want 503 while a check fails", rec.Code)
+	}
+}
+
+func TestHealthDetails(t *testing.T) {
+	reg := newHealthRegistry()
+	reg.Register(fakeChecker{name: "temporal", err: errors.New("dial tcp temporal.internal:7233: connection refused")})
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	req = req.WithContext(context.WithValue(req.Context(), loggerKey, logger))
+	rec := serve(handleHealth(reg, new(atomic.Bool)), req)
+	if strings.Contains(rec.Body.String(), "temporal.internal") {
+		t.Errorf("probe body leaks the check error: %s", rec.Body)
+	}
+	if e := findLog(t, logEntries(t, &buf), "health check failed"); e["check"] != "temporal" || !strings.Contains(fmt.Sprint(e["error"]), "temporal.internal") {
+		t.Errorf("log entry = %v, want the failed check and its error", e)
+	}
+
+	rec = serve(handleHealthDetails(reg), httptest.NewRequest(http.MethodGet, "/debug/health", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "temporal.internal") {
+		t.Errorf("details = %d %s, want 503 with the check error", rec.Code, rec.Body)
+	}
+}
+
+func TestHealthDetailsRequiresAdmin(t *testing.T) {
+	h := testRouter(t, nil)
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/debug/health", nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", rec.Code)
+	}
+	if rec := serve(h, authedRequest(t, http.MethodGet, "/debug/health", jwt.MapClaims{"sub": "u1"})); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin status = %d, want 403", rec.Code)
+	}
+	if rec := serve(h, authedRequest(t, http.MethodGet, "/debug/health", jwt.MapClaims{"sub": "ops", "scope": "admin"})); rec.Code != http.StatusOK {
+		t.Errorf("admin status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ipFilterOptions configures withIPFilter. A client in Deny is always
+// rejected; otherwise it must be in Allow, unless Allow is empty.
+type ipFilterOptions struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// withIPFilter rejects clients outside opts' allowlist, or inside its
+// denylist, with a 403. The client is identified by clientIP, so place
+// withClientIP before it when behind a proxy. Clients whose address can't
+// be parsed are rejected.
+func withIPFilter(opts ipFilterOptions) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, err := netip.ParseAddr(clientIP(r))
+			if err != nil || !opts.permits(ip.Unmap()) {
+				writeProblem(w, r, problemForbidden("client IP not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (opts ipFilterOptions) permits(ip netip.Addr) bool {
+	if prefixesContain(opts.Deny, ip) {
+		return false
+	}
+	return len(opts.Allow) == 0 || prefixesContain(opts.Allow, ip)
+}
+
+func prefixesContain(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefixes parses CIDRs such as "10.0.0.0/8". A bare address is
+// treated as a single-host prefix.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR or IP %q", v)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR or IP %q", v)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+// mustPrefixes parses CIDRs or fails the test.
+func mustPrefixes(t *testing.T, values ...string) []netip.Prefix {
+	t.Helper()
+	prefixes, err := parsePrefixes(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prefixes
+}
+
+func TestIPFilter(t *testing.T) {
+	h := adaptHandler(okHandler,
+		withClientIP(mustPrefixes(t, "192.0.2.1")),
+		withIPFilter(ipFilterOptions{
+			Allow: mustPrefixes(t, "10.0.0.0/8", "2001:db8::/32"),
+			Deny:  mustPrefixes(t, "10.9.9.9"),
+		}),
+	)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		wantStatus int
+	}{
+		{name: "allowed", remoteAddr: "10.1.2.3:1234", wantStatus: http.StatusOK},
+		{name: "allowed IPv6", remoteAddr: "[2001:db8::1]:1234", wantStatus: http.StatusOK},
+		{name: "blocked", remoteAddr: "203.0.113.9:1234", wantStatus: http.StatusForbidden},
+		{name: "denied within allowed range", remoteAddr: "10.9.9.9:1234", wantStatus: http.StatusForbidden},
+		{name: "XFF from untrusted peer ignored", remoteAddr: "203.0.113.9:1234", xff: []string{"10.1.2.3"}, wantStatus: http.StatusForbidden},
+		{name: "XFF from trusted proxy", remoteAddr: "192.0.2.1:1234", xff: []string{"10.1.2.3"}, wantStatus: http.StatusOK},
+		{name: "spoofed left-most XFF entry", remoteAddr: "192.0.2.1:1234", xff: []string{"10.1.2.3, 203.0.113.9"}, wantStatus: http.StatusForbidden},
+		{name: "spoofed entry in earlier header", remoteAddr: "192.0.2.1:1234", xff: []string{"10.1.2.3", "203.0.113.9"}, wantStatus: http.StatusForbidden},
+		{name: "trusted proxy without XFF", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusForbidden},
+		{name: "unparseable remote address", remoteAddr: "pipe", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			rec := serve(h, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	got, err := parsePrefixes([]string{"10.1.2.3/8", "192.0.2.1", "::ffff:192.0.2.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "192.0.2.2/32"}
+	for i, p := range got {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+	if _, err := parsePrefixes([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("parsePrefixes(10.0.0.0/33) succeeded, want error")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// jwksMinRefreshInterval bounds how often the key set is fetched outside the
+// regular refresh, whether an unknown kid forced it or the previous attempt
+// failed, so a client sending random kids can't turn us into a JWKS request
+// amplifier and an outage doesn't make every request refetch.
+const jwksMinRefreshInterval = 10 * time.Second
+
+// jwksKeyfunc resolves JWT verification keys from a JWKS endpoint. Keys are
+// cached in memory by kid and refetched every refreshInterval, or immediately
+// when a token references a kid that isn't in the cache (key rotation).
+// Concurrent callers share one fetch, made without holding the cache lock.
+type jwksKeyfunc struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	flight          singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func newJWKSKeyfunc(url string, refreshInterval time.Duration) *jwksKeyfunc {
+	return &jwksKeyfunc{
+		url:             url,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+		keys:            map[string]interface{}{},
+	}
+}
+
+// Keyfunc satisfies jwt.Keyfunc. Prefer KeyfuncContext where a request
+// context is at hand.
+func (k *jwksKeyfunc) Keyfunc(token *jwt.Token) (interface{}, error) {
+	return k.key(context.Background(), token)
+}
+
+// KeyfuncContext returns a jwt.Keyfunc that stops waiting for a key set
+// fetch when ctx is done.
+func (k *jwksKeyfunc) KeyfuncContext(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		return k.key(ctx, token)
+	}
+}
+
+func (k *jwksKeyfunc) key(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, fmt.Errorf("token has no kid header")
+	}
+
+	k.mu.RLock()
+	key, found := k.keys[kid]
+	stale := time.Since(k.fetchedAt) > k.refreshInterval
+	k.mu.RUnlock()
+
+	if stale {
+		if err := k.refresh(ctx, false); err != nil && !found {
+			return nil, err
+		}
+		key, found = k.lookup(kid)
+	}
+
+	// Unknown kid: the issuer may have rotated keys since our last fetch.
+	// Force a single refresh before giving up.
+	if !found {
+		if err := k.refresh(ctx, true); err != nil {
+			return nil, err
+		}
+		key, found = k.lookup(kid)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
+	return key, nil
+}
+
+func (k *jwksKeyfunc) lookup(kid string) (interface{}, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// refresh refetches the key set. It is skipped if any attempt, successful
+// or not, was made within jwksMinRefreshInterval, and unless force is set,
+// also if the keys were fetched within refreshInterval. The fetch outlives
+// a cancelled ctx, since other callers may be waiting on it.
+func (k *jwksKeyfunc) refresh(ctx context.Context, force bool) error {
+	if !k.due(force) {
+		return nil
+	}
+
+	ch := k.flight.DoChan("jwks", func() (interface{}, error) {
+		// Re-check: a fetch may have finished since this caller looked.
+		if !k.due(force) {
+			return nil, nil
+		}
+		keys, err := k.fetch(context.WithoutCancel(ctx))
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		k.attemptedAt = time.Now()
+		if err != nil {
+			return nil, fmt.Errorf("fetching jwks: %w", err)
+		}
+		k.keys = keys
+		k.fetchedAt = k.attemptedAt
+		return nil, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-ch:
+		return res.Err
+	}
+}
+
+func (k *jwksKeyfunc) due(force bool) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if time.Since(k.attemptedAt) < jwksMinRefreshInterval {
+		return false
+	}
+	return force || time.Since(k.fetchedAt) >= k.refreshInterval
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jwksKeyfunc) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, j := range set.Keys {
+		if j.Kid == "" || (j.Use != "" && j.Use != "sig") {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			// Skip keys we can't parse rather than failing the whole set.
+			continue
+		}
+		keys[j.Kid] = key
+	}
+	return keys, nil
+}
+
+func (j jwk) publicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", j.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
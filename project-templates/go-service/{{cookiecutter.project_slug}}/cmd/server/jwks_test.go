@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newJWKSServer(t *testing.T, kid string, pub *rsa.PublicKey) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		jwk := map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{jwk}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "user-1"})
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWKSKeyfuncResolvesKid(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, hits := newJWKSServer(t, "k1", &key.PublicKey)
+	k := newJWKSKeyfunc(srv.URL, time.Hour)
+
+	for range 3 {
+		token, err := jwt.Parse(signRS256(t, key, "k1"), k.KeyfuncContext(context.Background()))
+		if err != nil || !token.Valid {
+			t.Fatalf("Parse: %v", err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestJWKSKeyfuncThrottlesFailedFetches(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := newJWKSKeyfunc(srv.URL, time.Hour)
+
+	for range 5 {
+		if _, err := jwt.Parse(signRS256(t, key, "random-kid"), k.Keyfunc); err == nil {
+			t.Fatal("Parse succeeded without keys")
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times during an outage, want 1", got)
+	}
+}
+
+func TestJWKSKeyfuncStopsWaitingWhenContextDone(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := newJWKSKeyfunc(srv.URL, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = jwt.Parse(signRS256(t, key, "k1"), k.KeyfuncContext(ctx))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Parse error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Parse took %v after the context expired", elapsed)
+	}
+}
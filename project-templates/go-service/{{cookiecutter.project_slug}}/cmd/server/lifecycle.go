@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// phase is a long-running part of a process run by supervise, such as the
+// HTTP server or the Temporal worker. Run must shut down gracefully and
+// return once its context is done.
+type phase struct {
+	Name string
+	Run  func(ctx context.Context) error
+	// StopTimeout bounds how long Run may take to return once stopped;
+	// after it, supervise gives up on the phase and moves on. Zero waits
+	// indefinitely, for phases that bound their own shutdown.
+	StopTimeout time.Duration
+}
+
+// supervise runs phases concurrently until one of signals arrives, ctx is
+// done or any phase returns, so either the server or the worker failing
+// stops both. It then stops the phases one at a time in the order given,
+// each once the previous has returned: list the HTTP server before the
+// worker, so requests that start workflows drain before the worker goes.
+// It returns the first error, including a StopTimeout expiring; a shutdown
+// by signal returns nil. As with signal.Notify, an empty signals list
+// means every signal.
+func supervise(ctx context.Context, logger *slog.Logger, signals []os.Signal, phases ...phase) error {
+	ctx, stop := signal.NotifyContext(ctx, signals...)
+	defer stop()
+
+	type result struct {
+		i   int
+		err error
+	}
+	results := make(chan result, len(phases))
+	cancels := make([]context.CancelFunc, len(phases))
+	for i, p := range phases {
+		// Each phase is stopped in its turn, not when ctx is.
+		phaseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		defer cancel()
+		cancels[i] = cancel
+		go func() { results <- result{i, p.Run(phaseCtx)} }()
+	}
+
+	done := make([]bool, len(phases))
+	var firstErr error
+	record := func(r result) {
+		done[r.i] = true
+		if r.err != nil {
+			logger.Error("phase failed", "phase", phases[r.i].Name, "error", r.err)
+			if firstErr == nil {
+				firstErr = r.err
+			}
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+	case r := <-results:
+		record(r)
+	}
+
+	for i, p := range phases {
+		if done[i] {
+			continue
+		}
+		logger.Info("stopping phase", "phase", p.Name)
+		cancels[i]()
+		var timeout <-chan time.Time
+		if p.StopTimeout > 0 {
+			timer := time.NewTimer(p.StopTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		for !done[i] {
+			select {
+			case r := <-results:
+				record(r)
+			case <-timeout:
+				record(result{i, fmt.Errorf("%s did not stop within %v", p.Name, p.StopTimeout)})
+			}
+		}
+		logger.Info("phase stopped", "phase", p.Name)
+	}
+	return firstErr
+}
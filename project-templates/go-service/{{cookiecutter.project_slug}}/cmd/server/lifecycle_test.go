@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sequence records events from several goroutines.
+type sequence struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *sequence) add(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+// drainingPhase takes a while to return once stopped, like serveHTTP
+// draining requests, and records when it was stopped and returned.
+func drainingPhase(seq *sequence, name string) phase {
+	return phase{Name: name, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		seq.add(name + " stopping")
+		time.Sleep(20 * time.Millisecond)
+		seq.add(name + " stopped")
+		return nil
+	}}
+}
+
+func TestSuperviseStopsPhasesInOrder(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	var seq sequence
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := supervise(ctx, logger, nil, drainingPhase(&seq, "http"), drainingPhase(&seq, "worker"))
+	if err != nil {
+		t.Fatalf("supervise = %v, want nil", err)
+	}
+
+	want := []string{"http stopping", "http stopped", "worker stopping", "worker stopped"}
+	if !slices.Equal(seq.events, want) {
+		t.Errorf("events = %v, want %v", seq.events, want)
+	}
+
+	var logged []string
+	for _, e := range logEntries(t, &logs) {
+		logged = append(logged, fmt.Sprint(e["msg"], " ", e["phase"]))
+	}
+	wantLogged := []string{"stopping phase http", "phase stopped http", "stopping phase worker", "phase stopped worker"}
+	if !slices.Equal(logged, wantLogged) {
+		t.Errorf("logged %v, want %v", logged, wantLogged)
+	}
+}
+
+func TestSuperviseStopTimeout(t *testing.T) {
+	var seq sequence
+	stuck := phase{
+		Name:        "http",
+		Run:         func(context.Context) error { select {} },
+		StopTimeout: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := supervise(ctx, discardLogger, nil, stuck, drainingPhase(&seq, "worker"))
+	if err == nil || !strings.Contains(err.Error(), "http did not stop within 10ms") {
+		t.Errorf("supervise = %v, want the stop timeout", err)
+	}
+	if want := []string{"worker stopping", "worker stopped"}; !slices.Equal(seq.events, want) {
+		t.Errorf("events = %v, want %v: later phases still stop", seq.events, want)
+	}
+}
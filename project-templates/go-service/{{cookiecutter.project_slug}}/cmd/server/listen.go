@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixAddrPrefix marks an --addr that is a Unix socket path, as in
+// unix:/run/app/app.sock.
+const unixAddrPrefix = "unix:"
+
+// socketMode lets the owner and group connect; run the sidecar in the
+// socket's group.
+const socketMode = 0o660
+
+// listen opens the server's listener on addr: host:port for TCP or
+// unix:/path for a Unix socket. A Unix socket left behind by a process
+// that died is removed first; one a live server still answers on is an
+// error. Closing the listener, which http.Server.Shutdown does, removes the
+// socket file.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket at path unless a server is
+// listening on it. It refuses to remove anything that isn't a socket.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
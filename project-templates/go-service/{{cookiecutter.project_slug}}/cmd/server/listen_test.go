@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != socketMode {
+		t.Errorf("socket mode = %o, want %o", mode, socketMode)
+	}
+
+	srv := newHTTPServer("unix:"+path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "over the socket")
+	}), defaultServerTimeouts())
+	go srv.Serve(ln)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "over the socket" {
+		t.Errorf("body = %q, want %q", body, "over the socket")
+	}
+
+	if _, err := listen("unix:" + path); err == nil {
+		t.Error("listening on a socket in use succeeded, want an error")
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file after shutdown: %v, want it removed", err)
+	}
+}
+
+func TestListenUnixSocketStale(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket left behind by a process that died without closing it.
+	stale := filepath.Join(dir, "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	ln, err = listen("unix:" + stale)
+	if err != nil {
+		t.Fatalf("listening over a stale socket: %v", err)
+	}
+	ln.Close()
+
+	regular := filepath.Join(dir, "not.sock")
+	if err := os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen("unix:" + regular); err == nil {
+		t.Error("listening over a regular file succeeded, want an error")
+	}
+	if _, err := os.Stat(regular); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is an io.WriteCloser appending to a file that is rotated
+// once it would grow past maxSize bytes: the file is renamed to path.1,
+// replacing the previous backup, and a new one is started. Keeping a single
+// backup bounds disk use at about twice maxSize; ship logs from stdout if
+// you need more history. Writes are serialized, so handlers may share it.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// openRotatingFile opens path for appending, creating it if needed.
+func openRotatingFile(path string, maxSize int64) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize. A
+// single write larger than maxSize still goes to a fresh file whole, so
+// records are never split across files.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil && rf.f == nil {
+			return 0, fmt.Errorf("rotating %s: %w", rf.path, err)
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate moves the file to path.1 and starts a new one. If the rename
+// fails, it reopens path and keeps appending there, past maxSize, rather
+// than losing logs; the handle is only dropped if reopening fails too.
+func (rf *rotatingFile) rotate() error {
+	closeErr := rf.f.Close()
+	rf.f = nil
+	renameErr := os.Rename(rf.path, rf.path+".1")
+	if err := rf.open(); err != nil {
+		return errors.Join(closeErr, renameErr, err)
+	}
+	return errors.Join(closeErr, renameErr)
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
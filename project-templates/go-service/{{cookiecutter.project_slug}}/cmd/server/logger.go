@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logFlags configure the logger's format, destination and volume for every
+// command. log-level is declared with each command's other flags.
+func logFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "log-format",
+			Value:   "json",
+			Usage:   "Log format: json, or text for local development",
+			EnvVars: []string{"LOG_FORMAT"},
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "log-output",
+			Value:   "stderr",
+			Usage:   "Log destination: stderr or stdout",
+			EnvVars: []string{"LOG_OUTPUT"},
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "log-file",
+			Usage:   "Also write logs to this file, rotated when it reaches --log-max-size",
+			EnvVars: []string{"LOG_FILE"},
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "log-max-size",
+			Value:   100,
+			Usage:   "Size in megabytes at which --log-file is rotated to <log-file>.1",
+			EnvVars: []string{"LOG_MAX_SIZE"},
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "log-sample-rate",
+			Usage:   "Records kept per level per second; the rest are dropped (0 disables sampling)",
+			EnvVars: []string{"LOG_SAMPLE_RATE"},
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    "log-redact",
+			Value:   true,
+			Usage:   "Redact log attributes named in --log-redact-keys and mask email addresses",
+			EnvVars: []string{"LOG_REDACT"},
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    "log-redact-keys",
+			Value:   cli.NewStringSlice(defaultRedactKeys...),
+			Usage:   "Log attribute keys (case-insensitive) whose values are replaced with [REDACTED]",
+			EnvVars: []string{"LOG_REDACT_KEYS"},
+		}),
+	}
+}
+
+// logOptions configures setupLogger.
+type logOptions struct {
+	Level      string
+	Format     string // json or text
+	Output     string // stderr or stdout
+	File       string // also written to when set
+	MaxSizeMB  int    // File is rotated at this size
+	SampleRate int    // records per level per second; 0 keeps all
+	Redact     bool
+	RedactKeys []string
+}
+
+func newLogOptions(c *cli.Context) logOptions {
+	return logOptions{
+		Level:      c.String("log-level"),
+		Format:     c.String("log-format"),
+		Output:     c.String("log-output"),
+		File:       c.String("log-file"),
+		MaxSizeMB:  c.Int("log-max-size"),
+		SampleRate: c.Int("log-sample-rate"),
+		Redact:     c.Bool("log-redact"),
+		RedactKeys: c.StringSlice("log-redact-keys"),
+	}
+}
+
+// Validate reports every problem with opts at once.
+func (opts logOptions) Validate() error {
+	var errs []error
+	if opts.Format != "json" && opts.Format != "text" {
+		errs = append(errs, fmt.Errorf("log-format must be json or text, got %q", opts.Format))
+	}
+	if opts.Output != "stderr" && opts.Output != "stdout" {
+		errs = append(errs, fmt.Errorf("log-output must be stderr or stdout, got %q", opts.Output))
+	}
+	if opts.File != "" && opts.MaxSizeMB <= 0 {
+		errs = append(errs, fmt.Errorf("log-max-size must be positive, got %d", opts.MaxSizeMB))
+	}
+	if opts.SampleRate < 0 {
+		errs = append(errs, fmt.Errorf("log-sample-rate must not be negative, got %d", opts.SampleRate))
+	}
+	return errors.Join(errs...)
+}
+
+// setupLogger returns the logger described by opts, the LevelVar behind
+// it, so the level can be changed at runtime, and a func that closes the
+// log file, if any. With opts.File set, records go to both stderr (or
+// stdout) and the file, in the same format.
+func setupLogger(opts logOptions) (*slog.Logger, *slog.LevelVar, func() error, error) {
+	level := new(slog.LevelVar)
+	level.Set(parseLogLevel(opts.Level))
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var w io.Writer = os.Stderr
+	if opts.Output == "stdout" {
+		w = os.Stdout
+	}
+	var h slog.Handler = newLogHandler(w, opts.Format, handlerOpts)
+	closeFn := func() error { return nil }
+	if opts.File != "" {
+		f, err := openRotatingFile(opts.File, int64(opts.MaxSizeMB)<<20)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("opening log file: %w", err)
+		}
+		h = fanoutHandler{h, newLogHandler(f, opts.Format, handlerOpts)}
+		closeFn = f.Close
+	}
+	h = traceHandler{h}
+	if opts.Redact {
+		h = newRedactHandler(h, opts.RedactKeys)
+	}
+	// Sampling goes outermost so dropped records skip the other handlers.
+	if opts.SampleRate > 0 {
+		h = newSamplingHandler(h, opts.SampleRate)
+	}
+	return slog.New(h), level, closeFn, nil
+}
+
+// newLogHandler returns a human-readable text handler for format "text",
+// handy in local development, and a JSON handler otherwise.
+func newLogHandler(w io.Writer, format string, opts *slog.HandlerOptions) slog.Handler {
+	if format == "text" {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// parseLogLevel maps debug, info, warn and error (any case) to a level.
+// Anything else is warn.
+func parseLogLevel(levelStr string) slog.Level {
+	switch strings.ToUpper(levelStr) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "INFO":
+		return slog.LevelInfo
+	case "WARN":
+		return slog.LevelWarn
+	case "ERROR":
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// traceHandler adds trace_id and span_id to records whose context carries
+// a span, linking logs to traces. Only the *Context logging methods (and
+// Log) pass a context, so use them wherever a request is in scope.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+// fanoutHandler passes each record to every handler enabled for its level,
+// so one logger can write to several destinations. Handle returns the
+// handlers' errors joined, after trying them all.
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, hh := range h {
+		if hh.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, hh := range h {
+		if hh.Enabled(ctx, r.Level) {
+			errs = append(errs, hh.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(h))
+	for i, hh := range h {
+		out[i] = hh.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(h))
+	for i, hh := range h {
+		out[i] = hh.WithGroup(name)
+	}
+	return out
+}
+
+// samplingHandler passes on the first n records per level in each second
+// and drops the rest, bounding log volume during traffic spikes. Loggers
+// derived with With or WithGroup share the same budget.
+type samplingHandler struct {
+	slog.Handler
+	n     int
+	state *samplingState
+}
+
+type samplingState struct {
+	mu     sync.Mutex
+	second int64
+	counts map[slog.Level]int
+}
+
+func newSamplingHandler(h slog.Handler, n int) *samplingHandler {
+	return &samplingHandler{
+		Handler: h,
+		n:       n,
+		state:   &samplingState{counts: map[slog.Level]int{}},
+	}
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.state.allow(r.Time, r.Level, h.n) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), n: h.n, state: h.state}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), n: h.n, state: h.state}
+}
+
+// allow counts a record at t and reports whether it is within the budget
+// for its level and second.
+func (s *samplingState) allow(t time.Time, level slog.Level, n int) bool {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sec := t.Unix(); sec > s.second {
+		s.second = sec
+		clear(s.counts)
+	}
+	s.counts[level]++
+	return s.counts[level] <= n
+}
+
+// defaultRedactKeys are the attribute keys redacted unless --log-redact-keys
+// says otherwise.
+var defaultRedactKeys = []string{"authorization", "cookie", "password", "secret", "token"}
+
+// emailPattern matches email addresses; the domain is kept when masking.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@([A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)`)
+
+// redactHandler replaces the values of sensitive attributes with
+// "[REDACTED]" and masks email addresses in string values, including
+// attributes inside groups and those added with With. Messages are left
+// alone, so keep secrets and PII in attributes, not in format strings.
+type redactHandler struct {
+	slog.Handler
+	keys map[string]bool
+}
+
+func newRedactHandler(h slog.Handler, keys []string) *redactHandler {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = true
+	}
+	return &redactHandler{Handler: h, keys: set}
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(redacted), keys: h.keys}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), keys: h.keys}
+}
+
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	if h.keys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		return slog.String(a.Key, emailPattern.ReplaceAllString(v.String(), "***@$1"))
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
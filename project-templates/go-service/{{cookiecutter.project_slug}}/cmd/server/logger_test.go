@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetupLoggerFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		wantText bool
+	}{
+		{format: "json"},
+		{format: "text", wantText: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			logger, _, _, err := setupLogger(logOptions{Level: "info", Format: tt.format, Output: "stdout"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch h := logger.Handler().(traceHandler).Handler.(type) {
+			case *slog.TextHandler:
+				if !tt.wantText {
+					t.Errorf("handler = %T, want *slog.JSONHandler", h)
+				}
+			case *slog.JSONHandler:
+				if tt.wantText {
+					t.Errorf("handler = %T, want *slog.TextHandler", h)
+				}
+			default:
+				t.Errorf("unexpected handler %T", h)
+			}
+
+			var buf bytes.Buffer
+			slog.New(newLogHandler(&buf, tt.format, nil)).Info("hello")
+			if isJSON := json.Valid(buf.Bytes()); isJSON == tt.wantText {
+				t.Errorf("output %q: JSON = %v, want %v", buf.String(), isJSON, !tt.wantText)
+			}
+		})
+	}
+}
+
+func TestLogOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts logOptions
+		want []string
+	}{
+		{name: "valid", opts: logOptions{Format: "text", Output: "stdout"}},
+		{
+			name: "invalid format and output",
+			opts: logOptions{Format: "xml", Output: "file"},
+			want: []string{`log-format must be json or text, got "xml"`, `log-output must be stderr or stdout, got "file"`},
+		},
+		{
+			name: "file without max size",
+			opts: logOptions{Format: "json", Output: "stderr", File: "app.log"},
+			want: []string{"log-max-size must be positive, got 0"},
+		},
+		{
+			name: "negative sample rate",
+			opts: logOptions{Format: "json", Output: "stderr", SampleRate: -1},
+			want: []string{"log-sample-rate must not be negative, got -1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() = nil, want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %q, missing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newSamplingHandler(slog.NewJSONHandler(&buf, nil), 3))
+	derived := logger.With("component", "test")
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	emit := func(l *slog.Logger, at time.Time, level slog.Level) {
+		r := slog.NewRecord(at, level, "flood", 0)
+		l.Handler().Handle(context.Background(), r)
+	}
+	for i := 0; i < 100; i++ {
+		at := start.Add(time.Duration(i) * time.Millisecond)
+		emit(logger, at, slog.LevelInfo)
+		emit(derived, at, slog.LevelError)
+	}
+	// A new second gets a fresh budget.
+	emit(logger, start.Add(time.Second), slog.LevelInfo)
+
+	counts := map[string]int{}
+	for _, entry := range logEntries(t, &buf) {
+		counts[entry["level"].(string)]++
+	}
+	if counts["INFO"] != 4 || counts["ERROR"] != 3 {
+		t.Errorf("emitted INFO=%d ERROR=%d, want 4 and 3", counts["INFO"], counts["ERROR"])
+	}
+}
+
+func TestSetupLoggerSampling(t *testing.T) {
+	logger, _, _, _ := setupLogger(logOptions{Format: "json", SampleRate: 10})
+	if _, ok := logger.Handler().(*samplingHandler); !ok {
+		t.Errorf("handler = %T, want *samplingHandler", logger.Handler())
+	}
+	logger, _, _, _ = setupLogger(logOptions{Format: "json"})
+	if _, ok := logger.Handler().(*samplingHandler); ok {
+		t.Error("sampling enabled with SampleRate 0")
+	}
+}
+
+func TestFanoutHandler(t *testing.T) {
+	var stdout, file bytes.Buffer
+	logger := slog.New(fanoutHandler{
+		slog.NewJSONHandler(&stdout, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		slog.NewJSONHandler(&file, &slog.HandlerOptions{Level: slog.LevelInfo}),
+	}).With("component", "test")
+
+	logger.Info("detail")
+	logger.WithGroup("req").Warn("slow", "path", "/api")
+
+	if got := logEntries(t, &stdout); len(got) != 1 || got[0]["msg"] != "slow" || got[0]["component"] != "test" {
+		t.Errorf("stdout entries = %v, want just the warning", got)
+	}
+	got := logEntries(t, &file)
+	if len(got) != 2 || got[0]["msg"] != "detail" || got[1]["msg"] != "slow" {
+		t.Fatalf("file entries = %v, want both records", got)
+	}
+	if req, _ := got[1]["req"].(map[string]interface{}); req["path"] != "/api" {
+		t.Errorf("file entry = %v, want path grouped under req", got[1])
+	}
+}
+
+func TestSetupLoggerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger, _, closeLog, err := setupLogger(logOptions{Level: "warn", Format: "json", File: path, MaxSizeMB: 1, Redact: true, RedactKeys: defaultRedactKeys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := logger.Handler().(*redactHandler).Handler.(traceHandler).Handler
+	if _, ok := inner.(fanoutHandler); !ok {
+		t.Errorf("handler = %T, want records fanned out to stderr and the file", inner)
+	}
+	logger.Warn("to both", "password", "hunter2")
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := logEntries(t, bytes.NewBuffer(data))
+	if len(entries) != 1 || entries[0]["msg"] != "to both" || entries[0]["password"] != "[REDACTED]" {
+		t.Errorf("file entries = %v, want the redacted record", entries)
+	}
+
+	_, _, _, err = setupLogger(logOptions{File: filepath.Join(path, "not-a-dir", "app.log"), MaxSizeMB: 1})
+	if err == nil || !strings.Contains(err.Error(), "opening log file") {
+		t.Errorf("err = %v, want the open failure", err)
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newRedactHandler(slog.NewJSONHandler(&buf, nil), defaultRedactKeys))
+
+	logger.With("Authorization", "Bearer abc").WithGroup("req").Info("login",
+		"password", "hunter2",
+		"user", "jane.doe@example.com",
+		"note", "contact ops@corp.example.org or sales@corp.example.org",
+		slog.Group("headers", "cookie", "session=1", "accept", "*/*"),
+		"status", 200,
+	)
+
+	entries := logEntries(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if got := entry["Authorization"]; got != "[REDACTED]" {
+		t.Errorf("Authorization = %v, want [REDACTED]", got)
+	}
+	req := entry["req"].(map[string]interface{})
+	want := map[string]interface{}{
+		"password": "[REDACTED]",
+		"user":     "***@example.com",
+		"note":     "contact ***@corp.example.org or ***@corp.example.org",
+		"status":   float64(200),
+	}
+	for k, v := range want {
+		if req[k] != v {
+			t.Errorf("req.%s = %v, want %v", k, req[k], v)
+		}
+	}
+	headers := req["headers"].(map[string]interface{})
+	if headers["cookie"] != "[REDACTED]" || headers["accept"] != "*/*" {
+		t.Errorf("headers = %v, want cookie redacted and accept kept", headers)
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "abc") {
+		t.Errorf("secret leaked: %s", buf.String())
+	}
+}
+
+func TestTraceHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(traceHandler{slog.NewJSONHandler(&buf, nil)}).With("component", "test")
+
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	logger.InfoContext(ctx, "in span")
+	logger.InfoContext(context.Background(), "no span")
+
+	entries := logEntries(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	sc := span.SpanContext()
+	if entries[0]["trace_id"] != sc.TraceID().String() || entries[0]["span_id"] != sc.SpanID().String() {
+		t.Errorf("entry = %v, want trace_id %s and span_id %s", entries[0], sc.TraceID(), sc.SpanID())
+	}
+	if _, ok := entries[1]["trace_id"]; ok {
+		t.Errorf("entry without span has trace_id: %v", entries[1])
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// logEntries decodes JSON log lines.
+func logEntries(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decoding log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// findLog returns the last entry with message msg.
+func findLog(t *testing.T, entries []map[string]interface{}, msg string) map[string]interface{} {
+	t.Helper()
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i]["msg"] == msg {
+			return entries[i]
+		}
+	}
+	t.Fatalf("no %q log line in %v", msg, entries)
+	return nil
+}
+
+func TestHandlerLogsCarryRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h := adaptHandler(handleWhoami(whoamiOptions{}),
+		withRequestID(uuid.NewString),
+		withLogging(logger, accessLogLevel),
+		withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}),
+	)
+
+	req := authedRequest(t, http.MethodGet, "/whoami", jwt.MapClaims{"sub": "u1"})
+	req.Header.Set("X-Request-ID", "req-15")
+	if rec := serve(h, req); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	entry := findLog(t, logEntries(t, &logs), "whoami")
+	if entry["request_id"] != "req-15" || entry["sub"] != "u1" {
+		t.Errorf("whoami log = %v, want request_id=req-15 and sub=u1", entry)
+	}
+}
+
+func TestAccessLogStatusAndBytes(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	h := withLogging(logger, accessLogLevel)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such thing", http.StatusNotFound)
+	}))
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	entry := findLog(t, logEntries(t, &logs), "request")
+	if entry["status"] != float64(http.StatusNotFound) {
+		t.Errorf("status = %v, want 404", entry["status"])
+	}
+	if entry["bytes_written"] != float64(rec.Body.Len()) {
+		t.Errorf("bytes_written = %v, want %d", entry["bytes_written"], rec.Body.Len())
+	}
+	if entry["method"] != http.MethodGet || entry["path"] != "/missing" {
+		t.Errorf("log = %v, want method and path", entry)
+	}
+}
+
+func TestAccessLogLevel(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, "INFO"},
+		{http.StatusNotModified, "INFO"},
+		{http.StatusNotFound, "WARN"},
+		{http.StatusInternalServerError, "ERROR"},
+		{http.StatusServiceUnavailable, "ERROR"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			h := withLogging(logger, accessLogLevel)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := findLog(t, logEntries(t, &logs), "request")["level"]; got != tt.want {
+				t.Errorf("level = %v, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("override", func(t *testing.T) {
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&logs, nil))
+		quiet := func(int) slog.Level { return slog.LevelDebug }
+		h := withLogging(logger, quiet)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if logs.Len() != 0 {
+			t.Errorf("got %q, want the request logged below the handler's Info level", logs.String())
+		}
+	})
+}
+
+// TestDefaultLogLevelShowsRequests guards against a default --log-level that
+// hides healthy traffic: with the shipped defaults, a 200 must be logged.
+func TestDefaultLogLevelShowsRequests(t *testing.T) {
+	cfg := loadServerConfig(t)
+	// The file sink gets the same level as stderr and is easy to read back.
+	cfg.Log.File = filepath.Join(t.TempDir(), "app.log")
+	logger, _, closeLog, err := setupLogger(cfg.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(withLogging(logger, accessLogLevel)(okHandler), httptest.NewRequest(http.MethodGet, "/v1/whoami", nil))
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(cfg.Log.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := findLog(t, logEntries(t, bytes.NewBuffer(data)), "request")
+	if entry["status"] != float64(http.StatusOK) {
+		t.Errorf("request entry = %v, want status 200", entry)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned by an Authenticator for an unknown
+// user or a wrong password. Don't distinguish the two: telling clients
+// which one failed lets them enumerate users.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is an authenticated user, as the access tokens issued for it
+// will describe it.
+type Principal struct {
+	Subject string
+	Scope   string // space-delimited, as in the scope claim
+}
+
+// Authenticator checks a username and password. Implement it against your
+// user store and pass it to buildRouter to enable POST /login; the
+// built-in staticAuthenticator only covers --login-users.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (Principal, error)
+}
+
+// staticAuthenticator checks credentials against bcrypt hashes configured
+// up front. Usernames become token subjects.
+type staticAuthenticator struct {
+	hashes map[string][]byte
+}
+
+// dummyHash is compared against for unknown users so that a login for one
+// takes as long as a wrong password.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// newStaticAuthenticator parses "username:bcrypt-hash" entries, as in
+// --login-users.
+func newStaticAuthenticator(entries []string) (*staticAuthenticator, error) {
+	hashes := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		user, hash, ok := strings.Cut(e, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("login user %q must be username:bcrypt-hash", e)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("login user %q: invalid bcrypt hash", user)
+		}
+		hashes[user] = []byte(hash)
+	}
+	return &staticAuthenticator{hashes: hashes}, nil
+}
+
+func (a *staticAuthenticator) Authenticate(_ context.Context, username, password string) (Principal, error) {
+	hash, ok := a.hashes[username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Subject: username}, nil
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (r *loginRequest) Validate() error {
+	if r.Username == "" || r.Password == "" {
+		return errors.New("username and password are required")
+	}
+	return nil
+}
+
+// handleLogin checks the credentials in the body with auth and, if they
+// are valid, responds with a token pair for the principal. The refresh
+// token is only included when refresh tokens are enabled.
+func handleLogin(auth Authenticator, ti *tokenIssuer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, prob := decodeJSON[loginRequest](r)
+		if prob != nil {
+			writeProblem(w, r, prob)
+			return
+		}
+
+		principal, err := auth.Authenticate(r.Context(), req.Username, req.Password)
+		if errors.Is(err, ErrInvalidCredentials) {
+			LoggerFromContext(r.Context()).InfoContext(r.Context(), "login failed", "username", req.Username)
+			writeProblem(w, r, problemUnauthorized(ErrInvalidCredentials.Error()))
+			return
+		}
+		if err != nil {
+			LoggerFromContext(r.Context()).ErrorContext(r.Context(), "authenticating", "error", err)
+			writeProblem(w, r, problemInternal("internal server error"))
+			return
+		}
+
+		pair, err := ti.issue(principal.Subject, principal.Scope)
+		if err != nil {
+			LoggerFromContext(r.Context()).ErrorContext(r.Context(), "issuing tokens", "error", err)
+			writeProblem(w, r, problemInternal("internal server error"))
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, pair, http.StatusOK)
+	})
+}
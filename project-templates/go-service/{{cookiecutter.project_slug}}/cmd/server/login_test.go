@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHandleLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newStaticAuthenticator([]string{"alice:" + string(hash)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handleLogin(auth, newTestTokenIssuer(time.Now))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "valid", body: `{"username":"alice","password":"hunter2"}`, wantStatus: http.StatusOK},
+		{
+			name:       "wrong password",
+			body:       `{"username":"alice","password":"nope"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid credentials",
+		},
+		{
+			name:       "unknown user",
+			body:       `{"username":"bob","password":"hunter2"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: "invalid credentials",
+		},
+		{
+			name:       "missing password",
+			body:       `{"username":"alice"}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "username and password are required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := serve(h, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if got := errorMessage(t, rec.Body.Bytes()); got != tt.wantDetail {
+					t.Errorf("detail = %q, want %q", got, tt.wantDetail)
+				}
+				return
+			}
+
+			var pair tokenPair
+			if err := json.Unmarshal(rec.Body.Bytes(), &pair); err != nil {
+				t.Fatal(err)
+			}
+			if pair.RefreshToken == "" {
+				t.Error("no refresh token issued")
+			}
+			authed := httptest.NewRequest(http.MethodGet, "/", nil)
+			authed.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+			var sub string
+			withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, _ := ClaimsFromContext(r.Context())
+				sub = claims.Subject
+			})).ServeHTTP(httptest.NewRecorder(), authed)
+			if sub != "alice" {
+				t.Errorf("access token subject = %q, want alice", sub)
+			}
+		})
+	}
+}
+
+func TestNewStaticAuthenticatorRejectsBadHash(t *testing.T) {
+	if _, err := newStaticAuthenticator([]string{"alice:not-a-hash"}); err == nil {
+		t.Error("err = nil, want invalid bcrypt hash")
+	}
+}
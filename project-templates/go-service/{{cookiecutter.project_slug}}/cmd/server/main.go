@@ -1,82 +1,81 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"slices"
 	"strings"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"{{cookiecutter.go_mod}}/worker"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli/v2"
 )
 
 func main() {
+	serverFlags := newServerFlags()
+	workerFlags := newWorkerFlags()
+	allFlags := append(newServerFlags(), newWorkerOnlyFlags()...)
+	workflowStartFlags := newWorkflowStartFlags()
+
 	app := &cli.App{
 		Name:  "{{cookiecutter.project_slug}}",
 		Usage: "{{cookiecutter.description}}",
 		Commands: []*cli.Command{
 			{
-				Name:  "server",
-				Usage: "Start the HTTP server",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "addr",
-						Value:   ":8080",
-						EnvVars: []string{"SERVER_ADDR"},
-					},
-					&cli.StringFlag{
-						Name:    "log-level",
-						Value:   "warn",
-						EnvVars: []string{"LOG_LEVEL"},
-					},
-					&cli.StringFlag{
-						Name:    "jwt-secret",
-						EnvVars: []string{"AUTH_SECRET"},
-					},
-				},
+				Name:   "server",
+				Usage:  "Start the HTTP server",
+				Flags:  serverFlags,
+				Before: loadConfigFile(serverFlags),
 				Action: runServer,
 			},
 			{
-				Name:  "worker",
-				Usage: "Start the Temporal worker",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "temporal-address",
-						Value:   "localhost:7233",
-						EnvVars: []string{"TEMPORAL_ADDRESS"},
-					},
-					&cli.StringFlag{
-						Name:    "temporal-namespace",
-						Value:   "default",
-						EnvVars: []string{"TEMPORAL_NAMESPACE"},
-					},
-					&cli.StringFlag{
-						Name:    "task-queue",
-						Value:   "{{cookiecutter.project_slug}}",
-						EnvVars: []string{"TEMPORAL_TASK_QUEUE"},
-					},
-					&cli.StringFlag{
-						Name:    "log-level",
-						Value:   "warn",
-						EnvVars: []string{"LOG_LEVEL"},
-					},
-					&cli.BoolFlag{
-						Name:  "check-connection",
-						Usage: "Check Temporal connection and exit (for health checks)",
+				Name:   "worker",
+				Usage:  "Start the Temporal worker",
+				Flags:  workerFlags,
+				Before: loadConfigFile(workerFlags),
+				Action: runWorker,
+			},
+			{
+				Name:   "all",
+				Usage:  "Start the HTTP server and the Temporal worker in one process",
+				Flags:  allFlags,
+				Before: loadConfigFile(allFlags),
+				Action: runAll,
+			},
+			{
+				Name:  "workflow",
+				Usage: "Operate on workflows served by this service",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "start",
+						Usage:     "Start a workflow by type, passing JSON arguments",
+						ArgsUsage: "WORKFLOW_TYPE [JSON_ARG...]",
+						Flags:     workflowStartFlags,
+						Before:    loadConfigFile(workflowStartFlags),
+						Action:    runWorkflowStart,
 					},
 				},
-				Action: runWorker,
+			},
+			{
+				Name:   "version",
+				Usage:  "Print version, commit and build date",
+				Action: runVersion,
 			},
 		},
 	}
@@ -86,97 +85,304 @@ func main() {
 }
 
 func runServer(c *cli.Context) error {
-	addr := c.String("addr")
-	logger := setupLogger(c.String("log-level"))
-	jwtSecret := []byte(c.String("jwt-secret"))
+	cfg := newServerConfig(c)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	logger, logLevel, closeLog, err := setupLogger(cfg.Log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	logger.Info("starting", "version", version, "config", cfg)
+
+	ctx, stop := signal.NotifyContext(c.Context, shutdownSignals...)
+	defer stop()
+
+	reloadLogLevelOnHangup(ctx, logger, logLevel, func() (string, error) {
+		return configFileLogLevel(c)
+	})
+	cycleLogLevelOnSignal(ctx, logger, logLevel)
 
 	promRegistry := prometheus.NewRegistry()
+	promRegistry.MustRegister(newBuildInfoGauge())
+
+	var temporalClients *worker.ClientProvider
+	if cfg.TemporalAddress != "" {
+		temporalMetrics, closeTemporalMetrics := worker.NewMetricsHandler(logger, promRegistry)
+		defer closeTemporalMetrics.Close()
+		var err error
+		temporalClients, err = worker.NewClientProvider(logger, cfg.TemporalAddress, cfg.TemporalNamespace, cfg.TemporalConnection, temporalMetrics)
+		if err != nil {
+			return fmt.Errorf("configuring temporal client: %w", err)
+		}
+		defer temporalClients.Close()
+		temporalClients.SetConnectionMetrics(worker.NewConnectionMetrics(promRegistry))
+	}
+
+	return serveHTTP(ctx, cfg, logger, logLevel, promRegistry, temporalClients)
+}
+
+// probePaths are polled by orchestrators and scrapers, which reach the pod
+// directly; they're exempt from --require-https and maintenance mode.
+var probePaths = []string{"/livez", "/readyz", "/healthz", "/metrics"}
+
+// serveHTTP runs the HTTP server until ctx is done or a listener fails, then
+// shuts it down gracefully. temporalClients, when non-nil, backs the
+// Temporal readiness check.
+func serveHTTP(ctx context.Context, cfg serverConfig, logger *slog.Logger, logLevel *slog.LevelVar, promRegistry *prometheus.Registry, temporalClients *worker.ClientProvider) error {
+	metricsAllow, err := parsePrefixes(cfg.MetricsAllowCIDRs)
+	if err != nil {
+		return fmt.Errorf("parsing metrics-allow-cidr: %w", err)
+	}
+	trustedProxies, err := parsePrefixes(cfg.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parsing trusted-proxies: %w", err)
+	}
 
-	mux := http.NewServeMux()
+	if cfg.AuthDisabled {
+		logger.Warn("authentication disabled: protected routes are open to anyone", "subject", devSubject)
+	}
 
-	// Public endpoints
-	mux.Handle("GET /healthz", adaptHandler(
-		handleHealth(),
-		withRequestID(),
-		withLogging(logger),
-	))
+	shutdownTracing, err := setupTracing(ctx, cfg.OTelEndpoint)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+
+	jwtOpts := jwtAuthOptions{
+		Keyfunc:  hmacKeyfunc([]byte(cfg.JWTSecret)),
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		Leeway:   cfg.JWTLeeway,
+		Cookie:   cfg.JWTCookie,
+	}
+	if cfg.JWKSURL != "" {
+		jwks := newJWKSKeyfunc(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+		jwtOpts.Keyfunc = jwks.Keyfunc
+		jwtOpts.KeyfuncContext = jwks.KeyfuncContext
+	}
 
-	mux.Handle("GET /metrics", promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}))
+	// Flipped at the start of shutdown so /healthz fails and load balancers
+	// stop routing here while in-flight requests drain.
+	var shuttingDown atomic.Bool
 
-	// Protected endpoints
-	mux.Handle("GET /whoami", adaptHandler(
-		handleWhoami(logger),
-		withRequestID(),
-		withLogging(logger),
-		withMetrics(promRegistry),
-		withJWTAuth(jwtSecret),
-	))
+	// Register dependency checks (database, cache, ...) here.
+	health := newHealthRegistry()
+	if temporalClients != nil {
+		health.Register(temporalHealthChecker{clients: temporalClients})
+	}
 
-	server := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+	rateLimiter, closeRateLimiter, err := newLimiter(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("setting up rate limiting: %w", err)
 	}
+	defer closeRateLimiter()
 
-	// Graceful shutdown
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	audit, closeAudit, err := newAuditLogger(cfg.AuditLog, cfg.Log.Format)
+	if err != nil {
+		return fmt.Errorf("opening audit-log: %w", err)
+	}
+	defer closeAudit()
 
-	go func() {
-		logger.Info("server started", "addr", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("server failed", "error", err)
-			os.Exit(1)
+	// Replace with an Authenticator backed by your user store.
+	var auth Authenticator
+	if len(cfg.LoginUsers) > 0 {
+		if auth, err = newStaticAuthenticator(cfg.LoginUsers); err != nil {
+			return fmt.Errorf("parsing login-users: %w", err)
 		}
-	}()
+	}
+	// Likewise, replace with a KeyStore backed by your key database.
+	var apiKeys KeyStore
+	if len(cfg.APIKeys) > 0 {
+		if apiKeys, err = newHashedKeyStore(cfg.APIKeys); err != nil {
+			return fmt.Errorf("parsing api-keys: %w", err)
+		}
+	}
 
-	<-done
-	logger.Info("server shutting down")
+	mux := buildRouter(routerDeps{
+		cfg:          cfg,
+		logger:       logger,
+		logLevel:     logLevel,
+		registry:     promRegistry,
+		health:       health,
+		shuttingDown: &shuttingDown,
+		jwtOpts:      jwtOpts,
+		metricsAllow: metricsAllow,
+		rateLimiter:  rateLimiter,
+		tokens:       newTokenIssuer(cfg),
+		auth:         auth,
+		apiKeys:      apiKeys,
+		audit:        audit,
+		outbound:     newOutboundClient(context.WithoutCancel(ctx), cfg.OAuth),
+	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	securityHeaders := defaultSecurityHeaders()
+	securityHeaders.ContentSecurityPolicy = cfg.CSP
+	securityHeaders.HSTSMaxAge = cfg.HSTSMaxAge
+
+	// Toggled by SIGUSR2 during deploys.
+	var maintenance atomic.Bool
+	toggleMaintenanceOnSignal(ctx, logger, &maintenance)
+
+	var handler http.Handler = adaptHandler(mux,
+		withClientIP(trustedProxies),
+		withSecurityHeaders(securityHeaders),
+		withMaintenance(&maintenance, probePaths),
+		withGzip(),
+		withMaxBodySize(cfg.MaxBodyBytes),
+	)
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		handler = withCORS(defaultCORSOptions(cfg.CORSAllowedOrigins))(handler)
+	}
+	if cfg.RequireHTTPS != "off" {
+		handler = withRequireHTTPS(requireHTTPSOptions{
+			TrustedProxies: trustedProxies,
+			Redirect:       cfg.RequireHTTPS == "redirect",
+			Exempt:         probePaths,
+		})(handler)
+	}
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error("server shutdown failed", "error", err)
-		return err
+	server := newHTTPServer(cfg.Addr, handler, cfg.Timeouts)
+	if cfg.H2C {
+		server.Protocols = h2cProtocols()
+	}
+	server.ConnState = newConnTracker(promRegistry, cfg.MaxConns).ConnState
+
+	// Optional listener that only redirects plain HTTP to HTTPS
+	var redirectServer *http.Server
+	if cfg.HTTPRedirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:              cfg.HTTPRedirectAddr,
+			Handler:           handleHTTPSRedirect(cfg.Addr),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+	}
+
+	serveErr := runHTTPServer(ctx, cfg, logger, server, redirectServer, &shuttingDown)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("tracing shutdown failed", "error", err)
 	}
 
 	logger.Info("server stopped")
-	return nil
+	return serveErr
 }
 
-func runWorker(c *cli.Context) error {
-	logger := setupLogger(c.String("log-level"))
-	temporalAddr := c.String("temporal-address")
-	namespace := c.String("temporal-namespace")
-	taskQueue := c.String("task-queue")
+// runHTTPServer serves on cfg.Addr (and redirectServer, if any) until ctx is
+// done or a listener fails. It then flips shuttingDown, waits
+// --shutdown-delay and drains in-flight requests for up to
+// --shutdown-timeout before returning. It returns the listener failure, if
+// any, or the error from draining.
+func runHTTPServer(ctx context.Context, cfg serverConfig, logger *slog.Logger, server, redirectServer *http.Server, shuttingDown *atomic.Bool) error {
+	// Listener failures end up here and trigger the same graceful shutdown
+	// as a cancelled ctx.
+	serveErrs := make(chan error, 2)
+
+	go func() {
+		ln, err := listen(cfg.Addr)
+		if err != nil {
+			serveErrs <- fmt.Errorf("server failed: %w", err)
+			return
+		}
+		logger.Info("server started", "addr", cfg.Addr, "tls", cfg.TLS())
+		if cfg.TLS() {
+			server.TLSConfig = secureTLSConfig()
+			err = server.ServeTLS(ln, cfg.TLSCert, cfg.TLSKey)
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErrs <- fmt.Errorf("server failed: %w", err)
+		}
+	}()
+
+	if redirectServer != nil {
+		go func() {
+			logger.Info("https redirect started", "addr", redirectServer.Addr)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serveErrs <- fmt.Errorf("https redirect failed: %w", err)
+			}
+		}()
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-serveErrs:
+		logger.Error("server failed", "error", serveErr)
+	}
+	logger.Info("server shutting down", "delay", cfg.ShutdownDelay)
+	if serveErr == nil {
+		// ctx is already done; a second shutdown signal skips the rest of
+		// the delay and goes straight to draining.
+		delayCtx, stopDelay := signal.NotifyContext(context.Background(), shutdownSignals...)
+		markNotReady(delayCtx, shuttingDown, cfg.ShutdownDelay)
+		stopDelay()
+	} else {
+		// The listener is already gone, so there is nothing to drain.
+		shuttingDown.Store(true)
+	}
 
-	ctx := context.Background()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("https redirect shutdown failed", "error", err)
+		}
+	}
 
-	// Health check mode
-	if c.Bool("check-connection") {
-		return worker.CheckConnection(ctx, logger, temporalAddr, namespace)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("server shutdown failed", "error", err)
+		return err
 	}
+	return serveErr
+}
 
-	return worker.RunWorker(ctx, logger, temporalAddr, namespace, taskQueue)
+// serverTimeouts bounds how long a connection may spend in each phase, so
+// slow clients (Slowloris) can't hold connections open indefinitely.
+//
+// Write covers everything from the end of the request headers to the end of
+// the response, so it also caps streaming responses (SSE, large downloads);
+// such handlers should extend their own deadline with
+// http.NewResponseController(w).SetWriteDeadline. Keep Write above
+// request-timeout so withTimeout can still deliver its 503.
+type serverTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
 }
 
-// Logging setup
+func defaultServerTimeouts() serverTimeouts {
+	return serverTimeouts{
+		ReadHeader: 5 * time.Second,
+		Read:       30 * time.Second,
+		Write:      60 * time.Second,
+		Idle:       120 * time.Second,
+	}
+}
 
-func setupLogger(levelStr string) *slog.Logger {
-	var level slog.Level
-	switch strings.ToUpper(levelStr) {
-	case "DEBUG":
-		level = slog.LevelDebug
-	case "INFO":
-		level = slog.LevelInfo
-	case "WARN":
-		level = slog.LevelWarn
-	case "ERROR":
-		level = slog.LevelError
-	default:
-		level = slog.LevelWarn
+func newHTTPServer(addr string, handler http.Handler, timeouts serverTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
 	}
-	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// h2cProtocols serves HTTP/1 and cleartext HTTP/2 with prior knowledge on
+// one listener. The handler is the same, so middleware applies to both.
+func h2cProtocols() *http.Protocols {
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	return &p
 }
 
 // Middleware adapter pattern
@@ -195,12 +401,24 @@ type contextKey string
 const (
 	claimsKey    contextKey = "claims"
 	requestIDKey contextKey = "request_id"
+	loggerKey    contextKey = "logger"
+	tenantKey    contextKey = "tenant"
 )
 
-func withRequestID() adapter {
+// maxRequestIDLength caps incoming X-Request-ID values so clients can't
+// bloat every log line.
+const maxRequestIDLength = 128
+
+// withRequestID reuses a well-formed incoming X-Request-ID so IDs correlate
+// across services, and calls newID to generate one otherwise. Production
+// code passes uuid.NewString; tests can inject a deterministic generator.
+func withRequestID(newID func() string) adapter {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+			requestID := r.Header.Get("X-Request-ID")
+			if !validRequestID(requestID) {
+				requestID = newID()
+			}
 			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
 			w.Header().Set("X-Request-ID", requestID)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -208,61 +426,390 @@ func withRequestID() adapter {
 	}
 }
 
-func withLogging(logger *slog.Logger) adapter {
+// RequestIDFromContext returns the ID set by withRequestID, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// validRequestID accepts non-empty IDs of printable, header-safe characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// LoggerFromContext returns the request-scoped logger set by withLogging,
+// falling back to slog.Default outside a request.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// accessLogLevel is the default level policy for access log lines: 5xx at
+// Error, 4xx at Warn, everything else at Info.
+func accessLogLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// withLogging stores a logger carrying the request ID in the request context
+// for handlers to retrieve with LoggerFromContext, and logs each request at
+// the level levelFor picks for its status. Place it after withRequestID.
+func withLogging(logger *slog.Logger, levelFor func(status int) slog.Level) adapter {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			next.ServeHTTP(w, r)
-			logger.DebugContext(r.Context(), "request",
+			reqLogger := logger.With("request_id", RequestIDFromContext(r.Context()))
+			r, tenants := withTenantSlot(r)
+			ctx := context.WithValue(r.Context(), loggerKey, reqLogger)
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			attrs := []any{
 				"method", r.Method,
 				"path", r.URL.Path,
+				"client_ip", clientIP(r),
+				"status", wrapped.statusCode,
+				"bytes_written", wrapped.bytesWritten,
 				"duration", time.Since(start),
-			)
+			}
+			if id := tenants.get().ID; id != "" {
+				attrs = append(attrs, "tenant_id", id)
+			}
+			reqLogger.Log(r.Context(), levelFor(wrapped.statusCode), "request", attrs...)
 		})
 	}
 }
 
-func withJWTAuth(secret []byte) adapter {
+// hmacKeyfunc verifies tokens signed with a shared HMAC secret.
+func hmacKeyfunc(secret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	}
+}
+
+// signHMAC signs claims with HS256, the method hmacKeyfunc verifies.
+func signHMAC(secret []byte, claims jwt.MapClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+// jwtAuthOptions configures withJWTAuth. Issuer and Audience are only
+// enforced when non-empty. KeyfuncContext, when set, replaces Keyfunc with
+// one bound to the request context, for key lookups that do I/O. Leeway is
+// the clock skew tolerated in the exp, nbf and iat checks. Cookie, when
+// set, names a cookie to read the token from when there's no Authorization
+// header. Metrics, when set, counts outcomes.
+type jwtAuthOptions struct {
+	Keyfunc        jwt.Keyfunc
+	KeyfuncContext func(ctx context.Context) jwt.Keyfunc
+	Issuer         string
+	Audience       string
+	Leeway         time.Duration
+	Cookie         string
+	Metrics        *authMetrics
+}
+
+// withRecovery turns a panic anywhere downstream into a logged 500. Place it
+// directly after withRequestID so the log line carries the request ID and the
+// remaining middleware is covered too.
+func withRecovery(logger *slog.Logger) adapter {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				writeJSONError(w, "missing authorization header", http.StatusUnauthorized)
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// ErrAbortHandler is the sanctioned way to abort a response;
+				// let net/http handle it as usual.
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				logger.ErrorContext(r.Context(), "panic recovered",
+					"request_id", RequestIDFromContext(r.Context()),
+					"panic", fmt.Sprint(rec),
+					"stack", string(debug.Stack()),
+				)
+				writeProblem(w, r, problemInternal("internal server error"))
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withRequireContentType rejects POST, PUT and PATCH requests whose
+// Content-Type isn't one of mediaTypes (parameters such as charset are
+// ignored) with 415 Unsupported Media Type. Other methods carry no body to
+// check and pass through. Use it per route, as routes accept different
+// media types.
+func withRequireContentType(mediaTypes ...string) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !slices.Contains(mediaTypes, mediaType) {
+				writeProblem(w, r, problemUnsupportedMediaType("content type must be "+strings.Join(mediaTypes, " or ")))
 				return
 			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if tokenString == authHeader {
-				writeJSONError(w, "invalid authorization format", http.StatusUnauthorized)
+// withMaxBodySize caps request bodies at n bytes. Requests that declare a
+// larger Content-Length are rejected up front. Other bodies, such as
+// chunked ones, are wrapped in http.MaxBytesReader: the first read past the
+// limit answers 413 itself, unless the handler has already started its
+// response, and whatever the handler writes after that is dropped, so
+// handlers can't turn the failed read into a 400 or 500.
+func withMaxBodySize(n int64) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				writeProblem(w, r, newProblem(http.StatusRequestEntityTooLarge, "request body too large"))
+				return
+			}
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
 				return
 			}
+			lw := &bodyLimitWriter{ResponseWriter: w, r: r}
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, n), lw: lw}
+			next.ServeHTTP(lw, r)
+		})
+	}
+}
 
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return secret, nil
-			})
+// errBodyTooLarge is returned by writes after withMaxBodySize has answered
+// 413.
+var errBodyTooLarge = errors.New("request body too large: response already sent")
+
+// limitedBody tells its bodyLimitWriter when a read goes past the limit.
+type limitedBody struct {
+	io.ReadCloser
+	lw *bodyLimitWriter
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.lw.reject()
+	}
+	return n, err
+}
+
+// bodyLimitWriter answers 413 for withMaxBodySize once the body is too
+// large, and drops the handler's own response after that.
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	wrote    bool
+	rejected bool
+}
+
+func (lw *bodyLimitWriter) reject() {
+	if lw.wrote || lw.rejected {
+		return
+	}
+	lw.rejected = true
+	writeProblem(lw.ResponseWriter, lw.r, newProblem(http.StatusRequestEntityTooLarge, "request body too large"))
+}
+
+func (lw *bodyLimitWriter) WriteHeader(code int) {
+	if lw.rejected {
+		return
+	}
+	lw.wrote = true
+	lw.ResponseWriter.WriteHeader(code)
+}
+
+func (lw *bodyLimitWriter) Write(b []byte) (int, error) {
+	if lw.rejected {
+		return 0, errBodyTooLarge
+	}
+	lw.wrote = true
+	return lw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (lw *bodyLimitWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+func withJWTAuth(opts jwtAuthOptions) adapter {
+	var parserOpts []jwt.ParserOption
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	if opts.Leeway > 0 {
+		parserOpts = append(parserOpts, jwt.WithLeeway(opts.Leeway))
+	}
+	parser := jwt.NewParser(parserOpts...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, failure, detail := bearerToken(r, opts.Cookie)
+			if failure != "" {
+				opts.Metrics.observe(failure)
+				writeProblem(w, r, problemUnauthorized(detail))
+				return
+			}
+
+			keyfunc := opts.Keyfunc
+			if opts.KeyfuncContext != nil {
+				keyfunc = opts.KeyfuncContext(r.Context())
+			}
+			token, err := parser.Parse(tokenString, keyfunc)
 
 			if err != nil || !token.Valid {
-				writeJSONError(w, "invalid token", http.StatusUnauthorized)
+				if errors.Is(err, jwt.ErrTokenMalformed) {
+					opts.Metrics.observe(authMalformed)
+				} else {
+					opts.Metrics.observe(authInvalid)
+				}
+				writeProblem(w, r, problemUnauthorized(jwtErrorMessage(opts, token, err)))
 				return
 			}
 
 			if claims, ok := token.Claims.(jwt.MapClaims); ok {
-				ctx := context.WithValue(r.Context(), claimsKey, claims)
+				opts.Metrics.observe(authSuccess)
+				ctx := context.WithValue(r.Context(), claimsKey, newClaims(claims))
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 
-			writeJSONError(w, "invalid token claims", http.StatusUnauthorized)
+			opts.Metrics.observe(authInvalid)
+			writeProblem(w, r, problemUnauthorized("invalid token claims"))
 		})
 	}
 }
 
+// bearerToken returns the token from the Authorization header or, when
+// there's no header and cookie is set, from the cookie of that name. On
+// failure it returns the authMetrics result and the problem detail instead.
+func bearerToken(r *http.Request, cookie string) (token, failure, detail string) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if cookie != "" {
+			if c, err := r.Cookie(cookie); err == nil && c.Value != "" {
+				return c.Value, "", ""
+			}
+		}
+		return "", authMissing, "missing authorization header"
+	}
+
+	// The scheme is case-insensitive (RFC 9110); the token isn't.
+	scheme, token, _ := strings.Cut(authHeader, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", authMalformed, "authorization scheme must be Bearer"
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", authMissing, "empty bearer token"
+	}
+	return token, "", ""
+}
+
+// devSubject is the subject of every request when --auth-disabled is set.
+const devSubject = "dev"
+
+// withoutAuth stands in for withJWTAuth under --auth-disabled: every
+// request is let through with claims for devSubject and no scopes, so
+// handlers that read claims still work in local development.
+func withoutAuth() adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), claimsKey, newClaims(jwt.MapClaims{"sub": devSubject}))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// jwtErrorMessage distinguishes tokens that aren't JWTs at all, and issuer
+// and audience failures, from other validation errors. A missing iss/aud
+// claim surfaces from the parser as ErrTokenRequiredClaimMissing, so that
+// case is resolved against the claims.
+func jwtErrorMessage(opts jwtAuthOptions, token *jwt.Token, err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed token"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "invalid issuer"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "invalid audience"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing) && token != nil:
+		if opts.Issuer != "" {
+			if iss, _ := token.Claims.GetIssuer(); iss == "" {
+				return "invalid issuer"
+			}
+		}
+		if opts.Audience != "" {
+			if aud, _ := token.Claims.GetAudience(); len(aud) == 0 {
+				return "invalid audience"
+			}
+		}
+	}
+	return "invalid token"
+}
+
+// withRequireScope rejects requests whose claims grant none of the given
+// scopes. It must run after withJWTAuth. Scopes are read from the
+// space-delimited "scope" claim and from the "roles" claim.
+func withRequireScope(scopes ...string) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				writeProblem(w, r, problemForbidden("no claims in context"))
+				return
+			}
+
+			if claims.HasScope(scopes...) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writeProblem(w, r, problemForbidden("insufficient scope"))
+		})
+	}
+}
+
+// responseWriter records the status code and body size of a response for
+// the logging and metrics middleware.
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -270,67 +817,116 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-func withMetrics(registry *prometheus.Registry) adapter {
-	httpDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "http_request_duration_seconds",
-		Help:    "Duration of HTTP requests in seconds",
-		Buckets: prometheus.DefBuckets,
-	}, []string{"method", "path", "status"})
-
-	httpRequestsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "http_requests_total",
-		Help: "Total number of HTTP requests",
-	}, []string{"method", "path", "status"})
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += n
+	return n, err
+}
 
-	registry.MustRegister(httpDuration, httpRequestsTotal)
+// Flush passes through to the underlying writer so streaming responses (SSE)
+// work with the logging and metrics middleware in the chain.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-			next.ServeHTTP(wrapped, r)
-
-			duration := time.Since(start).Seconds()
-			status := fmt.Sprintf("%d", wrapped.statusCode)
-			labels := prometheus.Labels{
-				"method": r.Method,
-				"path":   r.URL.Path,
-				"status": status,
-			}
+// Hijack passes through to the underlying writer for WebSocket upgrades.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", rw.ResponseWriter)
+	}
+	return h.Hijack()
+}
 
-			httpDuration.With(labels).Observe(duration)
-			httpRequestsTotal.With(labels).Inc()
-		})
+// ReadFrom keeps the underlying writer's io.ReaderFrom (sendfile) fast path
+// for http.ServeContent and io.Copy, while still counting bytes.
+func (rw *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(rw.ResponseWriter, src)
 	}
+	rw.bytesWritten += int(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
 
 // Handlers
 
-func handleHealth() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, map[string]string{"status": "ok"}, http.StatusOK)
-	})
+// defaultWhoamiClaims are the claims /whoami returns unless configured
+// otherwise.
+var defaultWhoamiClaims = []string{"sub", "email", "scope"}
+
+// whoamiOptions configures handleWhoami.
+type whoamiOptions struct {
+	// Claims lists the claim keys returned to the caller; tokens may carry
+	// internal claims that shouldn't be echoed. Nil means
+	// defaultWhoamiClaims.
+	Claims []string
 }
 
-func handleWhoami(logger *slog.Logger) http.Handler {
+// whoamiResponse is the body of /whoami.
+type whoamiResponse struct {
+	Claims map[string]interface{} `json:"claims"`
+}
+
+func handleWhoami(opts whoamiOptions) http.Handler {
+	allowed := opts.Claims
+	if allowed == nil {
+		allowed = defaultWhoamiClaims
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		claims, ok := r.Context().Value(claimsKey).(jwt.MapClaims)
+		claims, ok := ClaimsFromContext(r.Context())
 		if !ok {
-			writeJSONError(w, "no claims in context", http.StatusInternalServerError)
+			writeProblem(w, r, problemInternal("no claims in context"))
 			return
 		}
-		writeJSON(w, map[string]interface{}{"claims": claims}, http.StatusOK)
+		LoggerFromContext(r.Context()).DebugContext(r.Context(), "whoami", "sub", claims.Subject)
+
+		visible := make(map[string]interface{}, len(allowed))
+		for _, key := range allowed {
+			if v, ok := claims.Raw[key]; ok {
+				visible[key] = v
+			}
+		}
+		writeResponse(w, r, whoamiResponse{Claims: visible}, http.StatusOK)
 	})
 }
 
 // Response helpers
 
+// writeJSON writes data as a JSON response with status code. data is
+// encoded before anything is written, so a value that can't be encoded
+// yields a 500 rather than a truncated body under the intended status.
 func writeJSON(w http.ResponseWriter, data interface{}, code int) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		slog.Default().Error("encoding JSON response", "error", err)
+		writeJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(data)
+	w.Write(append(body, '\n'))
 }
 
+// writeJSONError writes message as the detail of a problem+json response.
+// Prefer writeJSONErrorCtx or writeProblem, which also record the request
+// ID.
 func writeJSONError(w http.ResponseWriter, message string, code int) {
-	writeJSON(w, map[string]string{"error": message}, code)
+	writeProblem(w, nil, newProblem(code, message))
+}
+
+// writeJSONErrorCtx is writeJSONError with the request ID from ctx in the
+// body's instance field and the X-Request-ID header.
+func writeJSONErrorCtx(ctx context.Context, w http.ResponseWriter, message string, code int) {
+	writeProblemCtx(ctx, w, newProblem(code, message))
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testJWTSecret = "test-secret"
+
+// testToken signs claims with testJWTSecret, adding a one-hour exp unless
+// claims has one.
+func testToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// serve runs req through h and returns the recorded response.
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// authedRequest returns a request carrying a bearer token for claims.
+func authedRequest(t *testing.T, method, target string, claims jwt.MapClaims) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken(t, claims))
+	return req
+}
+
+func TestWriteJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       interface{}
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{name: "encodable", data: map[string]int{"n": 1}, wantStatus: http.StatusCreated, wantType: "application/json", wantBody: "{\"n\":1}\n"},
+		{name: "channel", data: map[string]interface{}{"ch": make(chan int)}, wantStatus: http.StatusInternalServerError, wantType: problemContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeJSON(rec, tt.data, http.StatusCreated)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
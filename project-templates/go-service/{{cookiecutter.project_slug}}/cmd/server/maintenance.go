@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maintenanceRetryAfter is the Retry-After sent while in maintenance mode.
+const maintenanceRetryAfter = 30 * time.Second
+
+// withMaintenance answers 503 with a Retry-After while on is set, so
+// clients back off during a deploy or migration. Exempt paths are served as
+// usual, so health checks keep the instance in rotation and it isn't
+// restarted mid-maintenance.
+func withMaintenance(on *atomic.Bool, exempt []string) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !on.Load() || slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(maintenanceRetryAfter.Seconds())))
+			writeProblem(w, r, problemUnavailable("down for maintenance, please try again shortly"))
+		})
+	}
+}
+
+// toggleMaintenanceOnSignal flips on each time the process gets SIGUSR2,
+// until ctx is done:
+//
+//	kill -USR2 $(pidof server)
+//
+// Like reloadLogLevelOnHangup, the handler is registered before it
+// returns.
+func toggleMaintenanceOnSignal(ctx context.Context, logger *slog.Logger, on *atomic.Bool) {
+	usr2 := make(chan os.Signal, 1)
+	signal.Notify(usr2, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(usr2)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-usr2:
+				enabled := !on.Load()
+				on.Store(enabled)
+				logOperatorChange(logger, "maintenance mode toggled", "enabled", enabled)
+			}
+		}
+	}()
+}
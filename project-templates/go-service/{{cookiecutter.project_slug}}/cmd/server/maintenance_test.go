@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	var on atomic.Bool
+	h := withMaintenance(&on, probePaths)(okHandler)
+
+	tests := []struct {
+		name       string
+		on         bool
+		path       string
+		wantStatus int
+	}{
+		{name: "off", path: "/v1/whoami", wantStatus: http.StatusOK},
+		{name: "on", on: true, path: "/v1/whoami", wantStatus: http.StatusServiceUnavailable},
+		{name: "on, health check", on: true, path: "/healthz", wantStatus: http.StatusOK},
+		{name: "on, readiness", on: true, path: "/readyz", wantStatus: http.StatusOK},
+		{name: "off again", path: "/v1/whoami", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			on.Store(tt.on)
+			rec := serve(h, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusServiceUnavailable {
+				return
+			}
+			if got := rec.Header().Get("Retry-After"); got != "30" {
+				t.Errorf("Retry-After = %q, want 30", got)
+			}
+			if got := rec.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+			}
+		})
+	}
+}
+
+func TestToggleMaintenanceOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var on atomic.Bool
+	toggleMaintenanceOnSignal(ctx, discardLogger, &on)
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []bool{true, false} {
+		if err := self.Signal(syscall.SIGUSR2); err != nil {
+			t.Skipf("sending SIGUSR2: %v", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for on.Load() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("maintenance = %v after SIGUSR2, want %v", on.Load(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// handleMetrics serves registry to Prometheus. Scrapes are gated separately
+// from the JWT-protected API: when token is set they must send it as a
+// bearer token, and when allow is non-empty they must come from one of
+// those networks. With neither, /metrics is open.
+func handleMetrics(registry *prometheus.Registry, token string, allow []netip.Prefix) http.Handler {
+	var adapters []adapter
+	if len(allow) > 0 {
+		adapters = append(adapters, withIPFilter(ipFilterOptions{Allow: allow}))
+	}
+	if token != "" {
+		adapters = append(adapters, withStaticToken(token))
+	}
+	return adaptHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), adapters...)
+}
+
+// withStaticToken requires "Authorization: Bearer <token>". It suits
+// machine clients such as scrapers that can't obtain a JWT.
+func withStaticToken(token string) adapter {
+	want := []byte(token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeProblem(w, r, problemUnauthorized("invalid or missing token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeLabel returns the ServeMux pattern that matched r, without its method,
+// so paths like /items/42 and /items/43 share the label /items/{id}. Requests
+// that matched no pattern are labeled "unknown" to keep cardinality bounded.
+// withMetrics uses it for the path label and withTracing for span names.
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unknown"
+	}
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
+
+// metricsOptions configures withMetrics. Buckets are the latency histogram
+// boundaries in seconds; pick them around the service's SLOs. Exclude lists
+// route patterns, as registered (e.g. "GET /healthz"), that aren't measured:
+// frequently polled probes add series without telling you much. Requests
+// slower than SLO are counted in http_requests_slo_violations_total, for
+// error budget dashboards that shouldn't depend on a bucket boundary
+// matching the objective; zero disables the counter.
+type metricsOptions struct {
+	Buckets []float64
+	Exclude []string
+	SLO     time.Duration
+}
+
+func defaultMetricsOptions() metricsOptions {
+	return metricsOptions{
+		Buckets: prometheus.DefBuckets,
+		Exclude: []string{"GET /healthz", "GET /livez", "GET /readyz"},
+	}
+}
+
+// withMetrics records request counts, latencies and response sizes. It must run inside a
+// ServeMux route so the path label is the route pattern (see routeLabel).
+// Instances on the same registry share collectors, so it can be added per
+// route; the first instance's buckets win.
+func withMetrics(registry *prometheus.Registry, opts metricsOptions) adapter {
+	httpDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds",
+		Buckets: opts.Buckets,
+	}, []string{"method", "path", "status"})
+
+	// Only the counter is labelled by tenant (see withTenant): the
+	// histograms would multiply the tenants by their buckets.
+	httpRequestsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests",
+	}, []string{"method", "path", "status", "tenant"})
+
+	// Sizes are measured before withGzip, which wraps the whole mux, so they
+	// are uncompressed body bytes.
+	httpResponseSize := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "Size of HTTP response bodies in bytes",
+		Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+	}, []string{"method", "path", "status"})
+
+	httpDuration = registerOrReuse(registry, httpDuration)
+	httpRequestsTotal = registerOrReuse(registry, httpRequestsTotal)
+	httpResponseSize = registerOrReuse(registry, httpResponseSize)
+
+	var sloViolations *prometheus.CounterVec
+	if opts.SLO > 0 {
+		sloViolations = registerOrReuse(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_slo_violations_total",
+			Help: "Total number of HTTP requests slower than the latency SLO",
+		}, []string{"method", "path"}))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(opts.Exclude, r.Pattern) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			r, tenants := withTenantSlot(r)
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+
+			elapsed := time.Since(start)
+			duration := elapsed.Seconds()
+			status := fmt.Sprintf("%d", wrapped.statusCode)
+			labels := prometheus.Labels{
+				"method": r.Method,
+				"path":   routeLabel(r),
+				"status": status,
+			}
+
+			httpDuration.With(labels).Observe(duration)
+			httpResponseSize.With(labels).Observe(float64(wrapped.bytesWritten))
+			labels["tenant"] = tenants.get().Label
+			httpRequestsTotal.With(labels).Inc()
+			if sloViolations != nil && elapsed > opts.SLO {
+				sloViolations.WithLabelValues(r.Method, labels["path"]).Inc()
+			}
+		})
+	}
+}
+
+// registerOrReuse registers c with registry, or returns the collector
+// already registered under the same descriptor, so building the same
+// metrics twice doesn't panic as MustRegister would. It panics on any
+// other registration error, such as a clashing metric of the same name.
+func registerOrReuse[T prometheus.Collector](registry prometheus.Registerer, c T) T {
+	err := registry.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
+
+// authMetrics counts withJWTAuth outcomes, so spikes in rejected tokens can
+// be alerted on. A nil *authMetrics records nothing.
+type authMetrics struct {
+	attempts *prometheus.CounterVec
+}
+
+// Results recorded by authMetrics.
+const (
+	authSuccess   = "success"
+	authMissing   = "missing"   // no Authorization header or an empty token
+	authMalformed = "malformed" // not a Bearer JWT
+	authInvalid   = "invalid"   // a JWT that failed verification
+)
+
+// newAuthMetrics registers auth_attempts_total with reg.
+func newAuthMetrics(reg prometheus.Registerer) *authMetrics {
+	m := &authMetrics{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_attempts_total",
+			Help: "Bearer token authentication attempts, by result.",
+		}, []string{"result"}),
+	}
+	// Export every result from the start so rate() works before the first
+	// failure.
+	for _, result := range []string{authSuccess, authMissing, authMalformed, authInvalid} {
+		m.attempts.WithLabelValues(result)
+	}
+	reg.MustRegister(m.attempts)
+	return m
+}
+
+// observe records one attempt that ended with result.
+func (m *authMetrics) observe(result string) {
+	if m == nil {
+		return
+	}
+	m.attempts.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricLabels returns the label sets recorded for the metric family name.
+func metricLabels(t *testing.T, reg *prometheus.Registry, name string) []map[string]string {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sets []map[string]string
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			sets = append(sets, labels)
+		}
+	}
+	return sets
+}
+
+func TestMetricsPathLabelIsRoutePattern(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	mux.Handle("GET /items/{id}", withMetrics(reg, defaultMetricsOptions())(okHandler))
+
+	serve(mux, httptest.NewRequest(http.MethodGet, "/items/42", nil))
+	serve(mux, httptest.NewRequest(http.MethodGet, "/items/43", nil))
+
+	sets := metricLabels(t, reg, "http_requests_total")
+	if len(sets) != 1 || sets[0]["path"] != "/items/{id}" {
+		t.Errorf("labels = %v, want one series with path /items/{id}", sets)
+	}
+}
+
+func TestMetricsPathLabelUnknownOutsideMux(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	serve(withMetrics(reg, defaultMetricsOptions())(okHandler), httptest.NewRequest(http.MethodGet, "/anything/123", nil))
+
+	sets := metricLabels(t, reg, "http_requests_total")
+	if len(sets) != 1 || sets[0]["path"] != "unknown" {
+		t.Errorf("labels = %v, want one series with path unknown", sets)
+	}
+}
+
+func TestMetricsCustomBuckets(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	buckets := []float64{0.0005, 0.001, 0.01}
+	serve(withMetrics(reg, metricsOptions{Buckets: buckets})(okHandler), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for _, f := range families {
+		if f.GetName() == "http_request_duration_seconds" {
+			for _, b := range f.GetMetric()[0].GetHistogram().GetBucket() {
+				got = append(got, b.GetUpperBound())
+			}
+		}
+	}
+	if !slices.Equal(got, buckets) {
+		t.Errorf("bucket bounds = %v, want %v", got, buckets)
+	}
+}
+
+func TestMetricsResponseSize(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	body := "hello, metrics"
+	// No WriteHeader call: the implicit 200 must still be measured.
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body[:5])
+		io.WriteString(w, body[5:])
+	})
+	serve(withMetrics(reg, defaultMetricsOptions())(h), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "http_response_size_bytes" {
+			continue
+		}
+		hist := f.GetMetric()[0].GetHistogram()
+		if hist.GetSampleCount() != 1 || hist.GetSampleSum() != float64(len(body)) {
+			t.Errorf("observed %d samples summing to %v, want 1 of %d", hist.GetSampleCount(), hist.GetSampleSum(), len(body))
+		}
+		return
+	}
+	t.Error("http_response_size_bytes not registered")
+}
+
+func TestHandleMetricsToken(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "scrape_test_total"}))
+	h := handleMetrics(reg, "scrape-secret", nil)
+
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+	}{
+		{name: "missing token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", auth: "Basic scrape-secret", wantStatus: http.StatusUnauthorized},
+		{name: "valid token", auth: "Bearer scrape-secret", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := serve(h, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			exposed := strings.Contains(rec.Body.String(), "scrape_test_total")
+			if exposed != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("metrics exposed = %v, want %v", exposed, !exposed)
+			}
+		})
+	}
+}
+
+func TestAuthMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := withJWTAuth(jwtAuthOptions{
+		Keyfunc: hmacKeyfunc([]byte(testJWTSecret)),
+		Metrics: newAuthMetrics(reg),
+	})(okHandler)
+
+	serve(h, authedRequest(t, http.MethodGet, "/", jwt.MapClaims{"sub": "u1"}))
+	serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	invalid := httptest.NewRequest(http.MethodGet, "/", nil)
+	invalid.Header.Set("Authorization", "Bearer "+testToken(t, jwt.MapClaims{"sub": "u1", "exp": time.Now().Add(-time.Hour).Unix()}))
+	serve(h, invalid)
+	malformed := httptest.NewRequest(http.MethodGet, "/", nil)
+	malformed.Header.Set("Authorization", "Bearer not-a-jwt")
+	serve(h, malformed)
+	serve(h, malformed)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, f := range families {
+		if f.GetName() != "auth_attempts_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			got[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+		}
+	}
+	want := map[string]float64{"success": 1, "missing": 1, "invalid": 1, "malformed": 2}
+	if !maps.Equal(got, want) {
+		t.Errorf("auth_attempts_total = %v, want %v", got, want)
+	}
+}
+
+func TestMetricsExclude(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	metrics := withMetrics(reg, defaultMetricsOptions())
+	mux.Handle("GET /healthz", metrics(okHandler))
+	mux.Handle("GET /items/{id}", metrics(okHandler))
+
+	serve(mux, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	serve(mux, httptest.NewRequest(http.MethodGet, "/items/42", nil))
+
+	for _, name := range []string{"http_requests_total", "http_request_duration_seconds", "http_response_size_bytes"} {
+		sets := metricLabels(t, reg, name)
+		if len(sets) != 1 || sets[0]["path"] != "/items/{id}" {
+			t.Errorf("%s labels = %v, want only /items/{id}", name, sets)
+		}
+	}
+}
+
+func TestMetricsSLOViolations(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	metrics := withMetrics(reg, metricsOptions{Buckets: prometheus.DefBuckets, SLO: 20 * time.Millisecond})
+	mux.Handle("GET /fast", metrics(okHandler))
+	mux.Handle("GET /slow", metrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	})))
+
+	serve(mux, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	serve(mux, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	serve(mux, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if got := metricValue(t, reg, "http_requests_slo_violations_total"); got != 2 {
+		t.Errorf("violations = %v, want 2", got)
+	}
+	sets := metricLabels(t, reg, "http_requests_slo_violations_total")
+	if len(sets) != 1 || sets[0]["path"] != "/slow" || sets[0]["method"] != http.MethodGet {
+		t.Errorf("labels = %v, want one series for GET /slow", sets)
+	}
+}
+
+func TestMetricsSLODisabled(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	serve(withMetrics(reg, defaultMetricsOptions())(okHandler), httptest.NewRequest(http.MethodGet, "/", nil))
+	if sets := metricLabels(t, reg, "http_requests_slo_violations_total"); len(sets) != 0 {
+		t.Errorf("violations recorded without an SLO: %v", sets)
+	}
+}
+
+func TestMetricsSharedRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	// Would panic if the second instance registered its own collectors.
+	mux.Handle("GET /a", withMetrics(reg, defaultMetricsOptions())(okHandler))
+	mux.Handle("GET /b", withMetrics(reg, defaultMetricsOptions())(okHandler))
+
+	serve(mux, httptest.NewRequest(http.MethodGet, "/a", nil))
+	serve(mux, httptest.NewRequest(http.MethodGet, "/b", nil))
+
+	var paths []string
+	for _, labels := range metricLabels(t, reg, "http_requests_total") {
+		paths = append(paths, labels["path"])
+	}
+	slices.Sort(paths)
+	if !slices.Equal(paths, []string{"/a", "/b"}) {
+		t.Errorf("http_requests_total paths = %v, want both routes in one family", paths)
+	}
+}
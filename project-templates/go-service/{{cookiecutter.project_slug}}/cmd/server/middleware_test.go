@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	h := adaptHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), withRequestID(func() string { return "req-1" }), withRecovery(logger))
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if got := errorMessage(t, rec.Body.Bytes()); got != "internal server error" || strings.Contains(rec.Body.String(), "boom") {
+		t.Errorf("error = %q, want a 500 that hides the panic value", got)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decoding log %q: %v", logs.String(), err)
+	}
+	if entry["level"] != "ERROR" || entry["panic"] != "boom" || entry["request_id"] != "req-1" {
+		t.Errorf("log = %v, want an ERROR with the panic and request ID", entry)
+	}
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "TestRecovery") {
+		t.Error("log has no stack trace")
+	}
+}
+
+func TestRecoveryRepanicsAbortHandler(t *testing.T) {
+	h := withRecovery(discardLogger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", got)
+		}
+	}()
+	serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestMaxBodySize(t *testing.T) {
+	// Like most handlers, this one treats any read error as a bad request;
+	// withMaxBodySize must still answer 413.
+	h := withMaxBodySize(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			writeProblem(w, r, problemBadRequest("could not read body"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	large := `{"name":"` + strings.Repeat("x", 32) + `"}`
+
+	tests := []struct {
+		name       string
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{name: "within limit", body: `{"name":"x"}`, wantStatus: http.StatusNoContent},
+		{name: "declared too large", body: large, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "streamed within limit", body: `{"name":"x"}`, chunked: true, wantStatus: http.StatusNoContent},
+		{name: "streamed too large", body: large, chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := serve(h, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	h := withRequireContentType("application/json")(okHandler)
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		wantStatus  int
+	}{
+		{name: "correct", method: http.MethodPost, contentType: "application/json", wantStatus: http.StatusOK},
+		{name: "with parameters", method: http.MethodPut, contentType: "Application/JSON; charset=utf-8", wantStatus: http.StatusOK},
+		{name: "missing", method: http.MethodPost, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "wrong", method: http.MethodPatch, contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed", method: http.MethodPost, contentType: "application/", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "get skipped", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "delete skipped", method: http.MethodDelete, contentType: "text/plain", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(`{}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := serve(h, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				if got := errorMessage(t, rec.Body.Bytes()); got != "content type must be application/json" {
+					t.Errorf("detail = %q", got)
+				}
+			}
+		})
+	}
+
+	multi := withRequireContentType("application/json", "application/merge-patch+json")(okHandler)
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	if rec := serve(multi, req); rec.Code != http.StatusOK {
+		t.Errorf("second media type: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("slow handler", func(t *testing.T) {
+		handlerDone := make(chan error, 1)
+		h := withTimeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			handlerDone <- r.Context().Err()
+			w.WriteHeader(http.StatusOK)
+		}))
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want 503", rec.Code)
+		}
+		if got := errorMessage(t, rec.Body.Bytes()); got != "request timed out" {
+			t.Errorf("error = %q, want request timed out", got)
+		}
+		select {
+		case err := <-handlerDone:
+			if err != context.DeadlineExceeded {
+				t.Errorf("handler context error = %v, want DeadlineExceeded", err)
+			}
+		case <-time.After(time.Second):
+			t.Error("handler context not cancelled")
+		}
+	})
+
+	t.Run("fast handler", func(t *testing.T) {
+		h := withTimeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", "yes")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("done"))
+		}))
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != http.StatusCreated || rec.Body.String() != "done" || rec.Header().Get("X-Handler") != "yes" {
+			t.Errorf("response = %d %q %v, want the handler's", rec.Code, rec.Body, rec.Header())
+		}
+	})
+}
+
+func TestRouteTimeouts(t *testing.T) {
+	// Both routes take 100ms; only the report route's override allows it.
+	// The server's write timeout is shorter still, so the override must
+	// push the write deadline back too.
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	})
+	timeouts := routeTimeouts{
+		Default:   20 * time.Millisecond,
+		Overrides: map[string]time.Duration{"GET /report": time.Second},
+		Write:     50 * time.Millisecond,
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /report", timeouts.For("GET /report")(slow))
+	mux.Handle("GET /whoami", timeouts.For("GET /whoami")(slow))
+
+	ts := httptest.NewUnstartedServer(mux)
+	ts.Config.WriteTimeout = timeouts.Write
+	ts.Start()
+	defer ts.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/report", wantStatus: http.StatusOK},
+		{path: "/whoami", wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := ts.Client().Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{name: "generated", incoming: "", want: "generated"},
+		{name: "propagated", incoming: "upstream-id.42:a_b", want: "upstream-id.42:a_b"},
+		{name: "invalid characters", incoming: "id with spaces", want: "generated"},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1), want: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			h := withRequestID(func() string { return "generated" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = RequestIDFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rec := serve(h, req)
+
+			if fromContext != tt.want {
+				t.Errorf("RequestIDFromContext = %q, want %q", fromContext, tt.want)
+			}
+			if got := rec.Header().Get("X-Request-ID"); got != tt.want {
+				t.Errorf("X-Request-ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := RequestIDFromContext(context.Background()); got != "" {
+		t.Errorf("RequestIDFromContext outside a request = %q, want empty", got)
+	}
+}
+
+func TestRequestIDsUnique(t *testing.T) {
+	h := withRequestID(uuid.NewString)(http.NotFoundHandler())
+
+	const n = 200
+	var mu sync.Mutex
+	seen := make(map[string]bool, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := serve(h, httptest.NewRequest(http.MethodGet, "/", nil)).Header().Get("X-Request-ID")
+			mu.Lock()
+			defer mu.Unlock()
+			seen[id] = true
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != n {
+		t.Errorf("%d distinct IDs for %d requests", len(seen), n)
+	}
+	for id := range seen {
+		if _, err := uuid.Parse(id); err != nil {
+			t.Errorf("ID %q is not a UUID: %v", id, err)
+		}
+	}
+}
+
+func TestResponseWriterPreservesInterfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = &responseWriter{ResponseWriter: rec, statusCode: http.StatusOK}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("wrapped writer is not an http.Flusher")
+	}
+	f.Flush()
+	if !rec.Flushed {
+		t.Error("Flush did not reach the underlying writer")
+	}
+
+	if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+		t.Error("Hijack succeeded on a recorder, want an error")
+	}
+
+	n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("streamed"))
+	if err != nil || n != 8 || rec.Body.String() != "streamed" {
+		t.Errorf("ReadFrom = %d, %v with body %q, want 8 bytes copied", n, err, rec.Body.String())
+	}
+	if got := w.(*responseWriter).bytesWritten; got != 8 {
+		t.Errorf("bytesWritten = %d, want 8", got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// msgpackHandle encodes with the current msgpack spec (str8 and bin
+// types). Struct fields use their json tag names, so both encodings carry
+// the same keys.
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// writeResponse writes data with code in the format r's Accept header
+// prefers: msgpack for application/msgpack (or application/x-msgpack),
+// JSON for everything else, including a missing or unsatisfiable Accept.
+// Use it for endpoints that high-throughput internal clients call; errors
+// stay problem+json via writeProblem.
+func writeResponse(w http.ResponseWriter, r *http.Request, data interface{}, code int) {
+	w.Header().Add("Vary", "Accept")
+	if negotiateMediaType(r.Header.Get("Accept")) != "application/msgpack" {
+		writeJSON(w, data, code)
+		return
+	}
+	// Encode before writing the header, so a failure can still be
+	// reported as a 500 rather than a truncated body.
+	var body []byte
+	if err := codec.NewEncoderBytes(&body, msgpackHandle).Encode(data); err != nil {
+		LoggerFromContext(r.Context()).Error("encoding msgpack response", "error", err)
+		writeProblem(w, r, problemInternal("failed to encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/msgpack")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// negotiateMediaType returns the supported media type with the highest
+// quality in accept, "application/json" or "application/msgpack". Ties go
+// to JSON, as does a header naming neither.
+func negotiateMediaType(accept string) string {
+	best, bestQ := "application/json", -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		var candidate string
+		switch mediaType {
+		case "application/msgpack", "application/x-msgpack":
+			candidate = "application/msgpack"
+		case "application/json", "application/*", "*/*":
+			candidate = "application/json"
+		default:
+			continue
+		}
+		if q > bestQ || (q == bestQ && candidate == "application/json") {
+			best, bestQ = candidate, q
+		}
+	}
+	return best
+}
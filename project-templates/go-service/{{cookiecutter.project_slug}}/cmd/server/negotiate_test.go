@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+func TestWriteResponse(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+	}{
+		{name: "no accept", wantContentType: "application/json"},
+		{name: "json", accept: "application/json", wantContentType: "application/json"},
+		{name: "msgpack", accept: "application/msgpack", wantContentType: "application/msgpack"},
+		{name: "x-msgpack", accept: "application/x-msgpack", wantContentType: "application/msgpack"},
+		{name: "wildcard", accept: "*/*", wantContentType: "application/json"},
+		{name: "unsupported", accept: "text/html", wantContentType: "application/json"},
+		{name: "msgpack preferred", accept: "application/json;q=0.5, application/msgpack", wantContentType: "application/msgpack"},
+		{name: "json preferred", accept: "application/msgpack;q=0.5, application/json", wantContentType: "application/json"},
+		{name: "tie goes to json", accept: "application/msgpack, application/json", wantContentType: "application/json"},
+		{name: "msgpack refused", accept: "application/msgpack;q=0", wantContentType: "application/json"},
+	}
+
+	data := buildInfo{Version: "v1.2.3", Commit: "abc123"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			writeResponse(rec, req, data, http.StatusCreated)
+
+			if rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want 201", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Fatalf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+
+			var body map[string]string
+			var err error
+			if tt.wantContentType == "application/msgpack" {
+				err = codec.NewDecoderBytes(rec.Body.Bytes(), msgpackHandle).Decode(&body)
+			} else {
+				err = json.Unmarshal(rec.Body.Bytes(), &body)
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body["version"] != "v1.2.3" || body["commit"] != "abc123" {
+				t.Errorf("body = %v, want version and commit under their json names", body)
+			}
+		})
+	}
+}
+
+// unencodable fails to marshal: the msgpack encoder uses
+// encoding.BinaryMarshaler for types that also implement
+// encoding.BinaryUnmarshaler.
+type unencodable struct{}
+
+func (unencodable) MarshalBinary() ([]byte, error) {
+	return nil, errors.New("boom")
+}
+
+func (*unencodable) UnmarshalBinary([]byte) error { return nil }
+
+func TestWriteResponseMsgpackEncodeError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	rec := httptest.NewRecorder()
+
+	writeResponse(rec, req, unencodable{}, http.StatusOK)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", got)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiTitle is the title of the OpenAPI document.
+const apiTitle = "{{cookiecutter.project_slug}} API"
+
+// routeDoc describes a route in the OpenAPI document. Request and Response
+// are values of the JSON body types, such as loginRequest{}, or nil for no
+// body; their schemas are derived from the json struct tags.
+type routeDoc struct {
+	Summary  string
+	Request  any
+	Response any
+	Auth     bool // requires a bearer token, Basic credentials or an API key
+	Status   int  // of a successful response; 200 if zero
+}
+
+// routeMux registers routes on a ServeMux together with their routeDocs, so
+// no route can be added without documenting it.
+type routeMux struct {
+	mux  *http.ServeMux
+	spec *apiSpec
+}
+
+func newRouteMux(spec *apiSpec) routeMux {
+	return routeMux{mux: http.NewServeMux(), spec: spec}
+}
+
+// Handle registers h for pattern, which must name a method, and documents
+// it with doc.
+func (m routeMux) Handle(pattern string, doc routeDoc, h http.Handler) {
+	m.mux.Handle(pattern, h)
+	m.spec.Add(pattern, doc)
+}
+
+// securitySchemes are the OpenAPI security schemes protected routes may
+// accept, by name.
+var securitySchemes = map[string]any{
+	"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+	"basicAuth":  map[string]any{"type": "http", "scheme": "basic"},
+	"apiKey":     map[string]any{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+}
+
+// apiSpec collects the routeDocs of routes as routeMux registers them and
+// renders them as an OpenAPI 3 document.
+type apiSpec struct {
+	routes      map[string]routeDoc // by ServeMux pattern, "GET /v1/whoami"
+	authSchemes []string            // keys of securitySchemes
+}
+
+// newAPISpec returns a spec whose protected routes accept any of
+// authSchemes, names from securitySchemes.
+func newAPISpec(authSchemes ...string) *apiSpec {
+	return &apiSpec{routes: map[string]routeDoc{}, authSchemes: authSchemes}
+}
+
+// Add documents the route registered as pattern.
+func (s *apiSpec) Add(pattern string, doc routeDoc) {
+	s.routes[pattern] = doc
+}
+
+// OpenAPI returns the OpenAPI 3 document describing the added routes.
+func (s *apiSpec) OpenAPI() ([]byte, error) {
+	paths := map[string]map[string]any{}
+	for pattern, doc := range s.routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		path, params := openAPIPath(path)
+		op := map[string]any{
+			"summary":   doc.Summary,
+			"responses": openAPIResponses(doc),
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if doc.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(schemaFor(reflect.TypeOf(doc.Request))),
+			}
+		}
+		if doc.Auth && len(s.authSchemes) > 0 {
+			// Alternatives: any one scheme suffices.
+			var security []map[string][]string
+			for _, name := range s.authSchemes {
+				security = append(security, map[string][]string{name: {}})
+			}
+			op["security"] = security
+		}
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+
+	schemes := map[string]any{}
+	for _, name := range s.authSchemes {
+		schemes[name] = securitySchemes[name]
+	}
+	return json.Marshal(map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": apiTitle, "version": version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas":         map[string]any{"Problem": schemaFor(reflect.TypeOf(problem{}))},
+			"securitySchemes": schemes,
+		},
+	})
+}
+
+// openAPIPath turns a ServeMux path into an OpenAPI one and lists its
+// wildcards as path parameters: /items/{id...} becomes /items/{id}, and
+// the {$} end anchor is dropped.
+func openAPIPath(path string) (string, []map[string]any) {
+	path = strings.TrimSuffix(path, "{$}")
+	var params []map[string]any
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(seg[1:len(seg)-1], "...")
+		segments[i] = "{" + name + "}"
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func openAPIResponses(doc routeDoc) map[string]any {
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := map[string]any{"description": http.StatusText(status)}
+	if doc.Response != nil {
+		ok["content"] = jsonContent(schemaFor(reflect.TypeOf(doc.Response)))
+	}
+	responses := map[string]any{strconv.Itoa(status): ok}
+	problemRef := map[string]any{"application/problem+json": map[string]any{
+		"schema": map[string]any{"$ref": "#/components/schemas/Problem"},
+	}}
+	if doc.Request != nil {
+		responses["400"] = map[string]any{"description": "Invalid request body", "content": problemRef}
+	}
+	if doc.Auth {
+		responses["401"] = map[string]any{"description": "Missing or invalid credentials", "content": problemRef}
+	}
+	return responses
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor derives a JSON schema from t as encoding/json would encode it.
+// Interfaces become an unconstrained schema.
+func schemaFor(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = schemaFor(f.Type)
+			if !slices.Contains(strings.Split(opts, ","), "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			slices.Sort(required)
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+// handleOpenAPI serves the document for spec. It's rendered once, on the
+// first request, so routes registered after this handler are included.
+func handleOpenAPI(spec *apiSpec) http.Handler {
+	render := sync.OnceValues(spec.OpenAPI)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, err := render()
+		if err != nil {
+			LoggerFromContext(r.Context()).ErrorContext(r.Context(), "rendering OpenAPI document", "error", err)
+			writeProblem(w, r, problemInternal("internal server error"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	h := testRouter(t, nil)
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary   string                    `json:"summary"`
+			Security  []map[string][]string     `json:"security"`
+			Responses map[string]map[string]any `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	whoami, ok := doc.Paths["/v1/whoami"]["get"]
+	if !ok {
+		t.Fatalf("GET /v1/whoami missing from paths %v", doc.Paths)
+	}
+	if want := []map[string][]string{{"bearerAuth": {}}}; !reflect.DeepEqual(whoami.Security, want) {
+		t.Errorf("security = %v, want %v", whoami.Security, want)
+	}
+	if _, ok := whoami.Responses["401"]; !ok {
+		t.Errorf("responses = %v, want a 401", whoami.Responses)
+	}
+	if _, ok := doc.Paths["/login"]; ok {
+		t.Error("POST /login documented though not registered")
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	got := schemaFor(reflect.TypeOf(tokenPair{}))
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"access_token":  map[string]any{"type": "string"},
+			"refresh_token": map[string]any{"type": "string"},
+			"token_type":    map[string]any{"type": "string"},
+			"expires_in":    map[string]any{"type": "integer"},
+		},
+		"required": []string{"access_token", "expires_in", "token_type"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaFor(tokenPair) = %v, want %v", got, want)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openAPIPath("/v1/files/{id}/{rest...}")
+	if path != "/v1/files/{id}/{rest}" {
+		t.Errorf("path = %q", path)
+	}
+	if len(params) != 2 || params[0]["name"] != "id" || params[1]["name"] != "rest" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	auth, err := newStaticAuthenticator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every optional route enabled.
+	routes := buildRoutes(routerDeps{
+		cfg:          serverConfig{RequestTimeout: time.Second, LatencyBuckets: prometheus.DefBuckets, EnablePprof: true},
+		logger:       discardLogger,
+		registry:     prometheus.NewRegistry(),
+		health:       newHealthRegistry(),
+		shuttingDown: new(atomic.Bool),
+		jwtOpts:      jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))},
+		tokens:       newTestTokenIssuer(time.Now),
+		auth:         auth,
+	})
+
+	rec := serve(routes.mux, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, optional := range []string{"POST /login", "POST /token/refresh", "GET /debug/pprof/", "GET /whoami"} {
+		if _, ok := routes.spec.routes[optional]; !ok {
+			t.Errorf("%s not registered", optional)
+		}
+	}
+	for pattern := range routes.spec.routes {
+		method, path, _ := strings.Cut(pattern, " ")
+		// The mux serves the pattern...
+		if _, got := routes.mux.Handler(httptest.NewRequest(method, strings.TrimSuffix(path, "{$}"), nil)); got != pattern {
+			t.Errorf("mux matched %q for %s, want %q", got, pattern, pattern)
+		}
+		// ...and the document describes it.
+		apiPath, _ := openAPIPath(path)
+		if _, ok := doc.Paths[apiPath][strings.ToLower(method)]; !ok {
+			t.Errorf("%s missing from the OpenAPI document", pattern)
+		}
+	}
+}
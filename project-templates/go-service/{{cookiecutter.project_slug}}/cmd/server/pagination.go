@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Page sizes for parsePageRequest.
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+	// maxCursorLength bounds the encoded cursor, which clients echo back.
+	maxCursorLength = 512
+)
+
+// pageRequest is the page a client asked for. Cursor is the decoded
+// position the previous page ended at, or "" for the first page.
+type pageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// page is the envelope of every paginated list response.
+type page[T any] struct {
+	Data       []T      `json:"data"`
+	Pagination pageInfo `json:"pagination"`
+}
+
+type pageInfo struct {
+	NextCursor string `json:"next_cursor,omitempty"` // absent on the last page
+	Total      *int   `json:"total,omitempty"`       // absent when not counted
+}
+
+// parsePageRequest reads the limit and cursor query parameters. limit
+// defaults to defaultPageLimit and must be between 1 and maxPageLimit;
+// cursor must be one that writePage handed out. Like decodeJSON, failures
+// are problems for writeProblem:
+//
+//	pr, prob := parsePageRequest(r)
+//	if prob != nil {
+//		writeProblem(w, r, prob)
+//		return
+//	}
+//	items, next, err := store.List(ctx, pr.Cursor, pr.Limit)
+//	...
+//	writePage(w, items, next, -1)
+func parsePageRequest(r *http.Request) (pageRequest, *problem) {
+	q := r.URL.Query()
+	pr := pageRequest{Limit: defaultPageLimit}
+
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageRequest{}, problemBadRequest(fmt.Sprintf("limit must be an integer between 1 and %d", maxPageLimit))
+		}
+		pr.Limit = limit
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		cursor, err := base64.RawURLEncoding.DecodeString(s)
+		if len(s) > maxCursorLength || err != nil || len(cursor) == 0 {
+			return pageRequest{}, problemBadRequest("invalid cursor")
+		}
+		pr.Cursor = string(cursor)
+	}
+	return pr, nil
+}
+
+// writePage writes items in the page envelope. nextCursor is the position
+// the next page starts after, such as the last item's ID, or "" on the
+// last page; it's encoded so clients treat it as opaque. Pass a negative
+// total when counting the collection is too costly.
+func writePage[T any](w http.ResponseWriter, items []T, nextCursor string, total int) {
+	if items == nil {
+		items = []T{} // an empty page is [], not null
+	}
+	p := page[T]{Data: items}
+	if nextCursor != "" {
+		p.Pagination.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(nextCursor))
+	}
+	if total >= 0 {
+		p.Pagination.Total = &total
+	}
+	writeJSON(w, p, http.StatusOK)
+}
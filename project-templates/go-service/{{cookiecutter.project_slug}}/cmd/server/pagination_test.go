@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	cursor := base64.RawURLEncoding.EncodeToString([]byte("item-42"))
+
+	tests := []struct {
+		name       string
+		query      string
+		want       pageRequest
+		wantDetail string
+	}{
+		{name: "defaults", query: "", want: pageRequest{Limit: defaultPageLimit}},
+		{name: "limit and cursor", query: "limit=10&cursor=" + cursor, want: pageRequest{Limit: 10, Cursor: "item-42"}},
+		{name: "smallest limit", query: "limit=1", want: pageRequest{Limit: 1}},
+		{name: "largest limit", query: "limit=200", want: pageRequest{Limit: maxPageLimit}},
+		{name: "zero limit", query: "limit=0", wantDetail: "limit must be an integer between 1 and 200"},
+		{name: "limit too large", query: "limit=201", wantDetail: "limit must be an integer between 1 and 200"},
+		{name: "negative limit", query: "limit=-5", wantDetail: "limit must be an integer between 1 and 200"},
+		{name: "non-numeric limit", query: "limit=ten", wantDetail: "limit must be an integer between 1 and 200"},
+		{name: "cursor not base64", query: "cursor=not*base64", wantDetail: "invalid cursor"},
+		{name: "cursor too long", query: "cursor=" + strings.Repeat("a", maxCursorLength+1), wantDetail: "invalid cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, p := parsePageRequest(httptest.NewRequest(http.MethodGet, "/items?"+tt.query, nil))
+			if tt.wantDetail != "" {
+				if p == nil || p.Status != http.StatusBadRequest || p.Detail != tt.wantDetail {
+					t.Errorf("problem = %v, want a 400 problem %q", p, tt.wantDetail)
+				}
+				return
+			}
+			if p != nil {
+				t.Fatal(p)
+			}
+			if got != tt.want {
+				t.Errorf("parsePageRequest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	type item struct {
+		ID string `json:"id"`
+	}
+
+	tests := []struct {
+		name       string
+		items      []item
+		nextCursor string
+		total      int
+		want       string
+	}{
+		{
+			name:       "middle page",
+			items:      []item{{ID: "a"}, {ID: "b"}},
+			nextCursor: "b",
+			total:      5,
+			want:       `{"data":[{"id":"a"},{"id":"b"}],"pagination":{"next_cursor":"Yg","total":5}}`,
+		},
+		{
+			name:  "last page, uncounted",
+			items: []item{{ID: "e"}},
+			total: -1,
+			want:  `{"data":[{"id":"e"}],"pagination":{}}`,
+		},
+		{
+			name: "empty",
+			want: `{"data":[],"pagination":{"total":0}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writePage(rec, tt.items, tt.nextCursor, tt.total)
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// A next_cursor handed out must be accepted back.
+	rec := httptest.NewRecorder()
+	writePage(rec, []item{{ID: "a"}}, "item-a", -1)
+	var body page[item]
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/items?cursor="+body.Pagination.NextCursor, nil)
+	if pr, p := parsePageRequest(req); p != nil || pr.Cursor != "item-a" {
+		t.Errorf("parsing next_cursor = %+v, %v, want cursor item-a", pr, p)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof mounts the net/http/pprof handlers under /debug/pprof/ on
+// mux, wrapped in adapters, which must authenticate callers. Named profiles
+// such as heap and goroutine are served by pprof.Index. Importing
+// net/http/pprof also registers on http.DefaultServeMux, which this server
+// never serves.
+func registerPprof(mux routeMux, adapters ...adapter) {
+	routes := []struct {
+		pattern string
+		summary string
+		h       http.HandlerFunc
+	}{
+		{"GET /debug/pprof/", "List profiles, or serve the named one such as heap or goroutine", pprof.Index},
+		{"GET /debug/pprof/cmdline", "Return the command line", pprof.Cmdline},
+		{"GET /debug/pprof/profile", "Profile CPU for ?seconds=N", pprof.Profile},
+		{"GET /debug/pprof/symbol", "Look up program counters", pprof.Symbol},
+		// go tool pprof posts the addresses it needs symbolized.
+		{"POST /debug/pprof/symbol", "Look up the program counters in the body", pprof.Symbol},
+		{"GET /debug/pprof/trace", "Trace execution for ?seconds=N", pprof.Trace},
+	}
+	for _, r := range routes {
+		mux.Handle(r.pattern, routeDoc{Summary: r.summary, Auth: true}, adaptHandler(r.h, adapters...))
+	}
+}
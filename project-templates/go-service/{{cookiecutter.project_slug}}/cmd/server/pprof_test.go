@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPprofRoutes(t *testing.T) {
+	jwtOpts := jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		authed     bool
+		path       string
+		wantStatus int
+	}{
+		{name: "disabled", path: "/debug/pprof/", wantStatus: http.StatusNotFound},
+		{name: "disabled named profile", path: "/debug/pprof/heap", wantStatus: http.StatusNotFound},
+		{name: "enabled without token", enabled: true, path: "/debug/pprof/", wantStatus: http.StatusUnauthorized},
+		{name: "index", enabled: true, authed: true, path: "/debug/pprof/", wantStatus: http.StatusOK},
+		{name: "named profile", enabled: true, authed: true, path: "/debug/pprof/heap", wantStatus: http.StatusOK},
+		{name: "cmdline", enabled: true, authed: true, path: "/debug/pprof/cmdline", wantStatus: http.StatusOK},
+		{name: "other routes unaffected", enabled: true, path: "/whoami", wantStatus: http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newRouteMux(newAPISpec())
+			mux.Handle("GET /whoami", routeDoc{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+			if tt.enabled {
+				registerPprof(mux, withJWTAuth(jwtOpts))
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authed {
+				req = authedRequest(t, http.MethodGet, tt.path, jwt.MapClaims{"sub": "ops"})
+			}
+			rec := serve(mux.mux, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// problemContentType is the media type for RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details object. Type is a URI that
+// identifies the kind of error for machines; Title is its short,
+// human-readable summary and Detail explains this occurrence. Instance
+// identifies the occurrence itself, which here is the request ID.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// newProblem returns a problem whose type is "about:blank", meaning the
+// status code alone describes the error, as RFC 7807 section 4.2 allows.
+func newProblem(status int, detail string) *problem {
+	return &problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func problemBadRequest(detail string) *problem {
+	return newProblem(http.StatusBadRequest, detail)
+}
+
+func problemUnauthorized(detail string) *problem {
+	return newProblem(http.StatusUnauthorized, detail)
+}
+
+func problemForbidden(detail string) *problem {
+	return newProblem(http.StatusForbidden, detail)
+}
+
+func problemNotFound(detail string) *problem {
+	return newProblem(http.StatusNotFound, detail)
+}
+
+func problemUnsupportedMediaType(detail string) *problem {
+	return newProblem(http.StatusUnsupportedMediaType, detail)
+}
+
+func problemTooManyRequests(detail string) *problem {
+	return newProblem(http.StatusTooManyRequests, detail)
+}
+
+func problemInternal(detail string) *problem {
+	return newProblem(http.StatusInternalServerError, detail)
+}
+
+func problemUnavailable(detail string) *problem {
+	return newProblem(http.StatusServiceUnavailable, detail)
+}
+
+// writeProblem writes p as application/problem+json. When p has no
+// Instance, the request ID from r's context is used so clients have a
+// handle to quote to support. r may be nil.
+func writeProblem(w http.ResponseWriter, r *http.Request, p *problem) {
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+	}
+	writeProblemCtx(ctx, w, p)
+}
+
+// writeProblemCtx is writeProblem for callers that hold a context rather
+// than the request. It also echoes the request ID as X-Request-ID in case
+// the header was dropped, e.g. by a handler that replaced the header map.
+func writeProblemCtx(ctx context.Context, w http.ResponseWriter, p *problem) {
+	requestID := RequestIDFromContext(ctx)
+	if p.Instance == "" {
+		p.Instance = requestID
+	}
+	if requestID != "" && w.Header().Get("X-Request-ID") == "" {
+		w.Header().Set("X-Request-ID", requestID)
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWriteProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem *problem
+		want    problem
+	}{
+		{
+			name:    "constructor",
+			problem: problemNotFound("no such item"),
+			want: problem{
+				Type:     "about:blank",
+				Title:    "Not Found",
+				Status:   http.StatusNotFound,
+				Detail:   "no such item",
+				Instance: "req-1",
+			},
+		},
+		{
+			name:    "explicit instance kept",
+			problem: &problem{Type: "https://example.com/probs/out-of-credit", Title: "Out of credit", Status: http.StatusForbidden, Instance: "/account/12345"},
+			want: problem{
+				Type:     "https://example.com/probs/out-of-credit",
+				Title:    "Out of credit",
+				Status:   http.StatusForbidden,
+				Instance: "/account/12345",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := adaptHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeProblem(w, r, tt.problem)
+			}), withRequestID(func() string { return "req-1" }))
+
+			rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.want.Status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want.Status)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			var got problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+			}
+			if got != tt.want {
+				t.Errorf("body = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONErrorIsProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSONError(rec, "bad input", http.StatusBadRequest)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", got)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	want := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Bad Request",
+		"status": float64(http.StatusBadRequest),
+		"detail": "bad input",
+	}
+	if len(body) != len(want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %v, want %v", k, body[k], v)
+		}
+	}
+}
+
+func TestErrorRequestIDMatchesHeader(t *testing.T) {
+	h := adaptHandler(okHandler,
+		withRequestID(uuid.NewString),
+		withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}),
+	)
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	header := rec.Header().Get("X-Request-ID")
+	if header == "" || p.Instance != header {
+		t.Errorf("instance = %q, X-Request-ID = %q, want equal and non-empty", p.Instance, header)
+	}
+}
+
+func TestWriteJSONErrorCtx(t *testing.T) {
+	ctx := context.WithValue(context.Background(), requestIDKey, "req-2")
+	rec := httptest.NewRecorder()
+	writeJSONErrorCtx(ctx, rec, "boom", http.StatusInternalServerError)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "req-2" {
+		t.Errorf("X-Request-ID = %q, want req-2", got)
+	}
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	if p.Instance != "req-2" || p.Detail != "boom" {
+		t.Errorf("body = %+v, want instance req-2 and detail boom", p)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// limiter decides whether the client identified by key may make another
+// request and, if not, how long it should wait. The in-memory limiter
+// counts per replica; redisLimiter shares counts across replicas.
+type limiter interface {
+	Allow(ctx context.Context, key string) (bool, time.Duration)
+}
+
+// rateLimitIdleTTL is how long a client's bucket is kept after its last
+// request. Idle buckets are swept lazily so the map doesn't grow unbounded.
+const rateLimitIdleTTL = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// memoryLimiter holds one token bucket per client key in this process.
+type memoryLimiter struct {
+	rps   rate.Limit
+	burst int
+	now   func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func newMemoryLimiter(rps float64, burst int) *memoryLimiter {
+	return &memoryLimiter{
+		rps:       rate.Limit(rps),
+		burst:     burst,
+		now:       time.Now,
+		clients:   map[string]*clientLimiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (rl *memoryLimiter) Allow(_ context.Context, key string) (bool, time.Duration) {
+	now := rl.now()
+
+	rl.mu.Lock()
+	if now.Sub(rl.lastSweep) > rateLimitIdleTTL {
+		for k, c := range rl.clients {
+			if now.Sub(c.lastSeen) > rateLimitIdleTTL {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+	c, ok := rl.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rl.rps, rl.burst)}
+		rl.clients[key] = c
+	}
+	c.lastSeen = now
+	rl.mu.Unlock()
+
+	res := c.limiter.ReserveN(now, 1)
+	if !res.OK() {
+		return false, time.Second
+	}
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// newLimiter returns the limiter selected by cfg.RateLimitBackend, or nil
+// when rate limiting is disabled. Call close on shutdown to release the
+// backend's connections.
+func newLimiter(cfg serverConfig, logger *slog.Logger) (limiter, func() error, error) {
+	noop := func() error { return nil }
+	if cfg.RateLimitRPS <= 0 {
+		return nil, noop, nil
+	}
+	if cfg.RateLimitBackend != "redis" {
+		return newMemoryLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst), noop, nil
+	}
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing redis-url: %w", err)
+	}
+	client := redis.NewClient(opts)
+	return newRedisLimiter(client, cfg.RateLimitRPS, cfg.RateLimitBurst, logger), client.Close, nil
+}
+
+// withRateLimit rejects clients that l doesn't allow with a 429 and a
+// Retry-After header. Clients are keyed by JWT subject when claims are in
+// context (so place it after withJWTAuth) and by clientIP otherwise. A nil
+// l disables limiting.
+func withRateLimit(l limiter) adapter {
+	if l == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter := l.Allow(r.Context(), rateLimitKey(r))
+			if !ok {
+				secs := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(secs))
+				writeProblem(w, r, problemTooManyRequests("rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if claims, ok := ClaimsFromContext(r.Context()); ok && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	return "ip:" + clientIP(r)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubLimiter allows the first n calls per key.
+type stubLimiter struct {
+	n     int
+	calls map[string]int
+}
+
+func (l *stubLimiter) Allow(_ context.Context, key string) (bool, time.Duration) {
+	l.calls[key]++
+	if l.calls[key] > l.n {
+		return false, 1500 * time.Millisecond
+	}
+	return true, 0
+}
+
+func TestWithRateLimit(t *testing.T) {
+	l := &stubLimiter{n: 1, calls: map[string]int{}}
+	h := withRateLimit(l)(okHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if rec := serve(h, req); rec.Code != http.StatusOK {
+		t.Fatalf("first status = %d, want 200", rec.Code)
+	}
+	rec := serve(h, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if l.calls["ip:192.0.2.1"] != 2 {
+		t.Errorf("calls = %v, want two for ip:192.0.2.1", l.calls)
+	}
+}
+
+func TestWithRateLimitNil(t *testing.T) {
+	h := withRateLimit(nil)(okHandler)
+	for i := 0; i < 3; i++ {
+		if rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil)); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	l := newMemoryLimiter(1, 2)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(ctx, "a"); !ok {
+			t.Fatalf("request %d denied within burst", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow(ctx, "a")
+	if ok || retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("Allow = %v, %v, want denied with retry within 1s", ok, retryAfter)
+	}
+	if ok, _ := l.Allow(ctx, "b"); !ok {
+		t.Error("other key denied")
+	}
+}
+
+func TestWithRateLimitMemory(t *testing.T) {
+	h := withRateLimit(newMemoryLimiter(1, 2))(okHandler)
+	get := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		return serve(h, req)
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := get("192.0.2.1"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200 within burst", i+1, rec.Code)
+		}
+	}
+	rec := get("192.0.2.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status after burst = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if rec := get("192.0.2.2"); rec.Code != http.StatusOK {
+		t.Errorf("other client status = %d, want 200 from its own bucket", rec.Code)
+	}
+}
+
+func TestMemoryLimiterEvictsIdleBuckets(t *testing.T) {
+	l := newMemoryLimiter(1, 1)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	l.Allow(ctx, "idle")
+	now = now.Add(rateLimitIdleTTL / 2)
+	l.Allow(ctx, "active")
+	now = now.Add(rateLimitIdleTTL/2 + time.Second)
+	l.Allow(ctx, "active")
+
+	if _, ok := l.clients["idle"]; ok {
+		t.Error("idle bucket kept past rateLimitIdleTTL")
+	}
+	if _, ok := l.clients["active"]; !ok {
+		t.Error("active bucket evicted")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript implements the generic cell rate algorithm: each key stores
+// the theoretical arrival time (TAT) of the next request, in milliseconds
+// of Redis' clock so replicas with skewed clocks agree. A request is
+// allowed if it keeps the TAT within burst intervals of now.
+//
+// KEYS[1] is the client key; ARGV[1] the emission interval in ms and
+// ARGV[2] the burst. It returns {allowed, retry_after_ms}.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local over = new_tat - now - interval * burst
+if over > 0 then
+	return {0, math.ceil(over)}
+end
+redis.call("SET", KEYS[1], tostring(new_tat), "PX", math.ceil(new_tat - now))
+return {1, 0}
+`)
+
+// redisLimiter is a token bucket per client key kept in Redis, so every
+// replica draws on the same budget. If Redis is unreachable it fails open,
+// logging the error, rather than rejecting all traffic.
+type redisLimiter struct {
+	client   redis.Scripter
+	interval float64 // ms between tokens
+	burst    int
+	logger   *slog.Logger
+}
+
+func newRedisLimiter(client redis.Scripter, rps float64, burst int, logger *slog.Logger) *redisLimiter {
+	return &redisLimiter{
+		client:   client,
+		interval: 1000 / rps,
+		burst:    burst,
+		logger:   logger,
+	}
+}
+
+func (rl *redisLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	res, err := gcraScript.Run(ctx, rl.client, []string{"ratelimit:" + key}, rl.interval, rl.burst).Int64Slice()
+	if err != nil || len(res) != 2 {
+		rl.logger.WarnContext(ctx, "rate limit check failed, allowing request", "error", err)
+		return true, 0
+	}
+	if res[0] == 1 {
+		return true, 0
+	}
+	return false, time.Duration(math.Max(float64(res[1]), 1)) * time.Millisecond
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func TestRedisLimiter(t *testing.T) {
+	mr, client := newTestRedis(t)
+	now := time.Unix(1700000000, 0)
+	mr.SetTime(now)
+	ctx := context.Background()
+
+	// Two replicas share one budget of rps 1, burst 2.
+	replicas := []*redisLimiter{
+		newRedisLimiter(client, 1, 2, discardLogger),
+		newRedisLimiter(client, 1, 2, discardLogger),
+	}
+	for i, l := range replicas {
+		if ok, _ := l.Allow(ctx, "ip:192.0.2.1"); !ok {
+			t.Fatalf("request %d denied within burst", i+1)
+		}
+	}
+	ok, retryAfter := replicas[0].Allow(ctx, "ip:192.0.2.1")
+	if ok || retryAfter != time.Second {
+		t.Errorf("over burst: Allow = %v, %v, want false, 1s", ok, retryAfter)
+	}
+	if ok, _ := replicas[1].Allow(ctx, "ip:192.0.2.2"); !ok {
+		t.Error("other key denied")
+	}
+
+	mr.SetTime(now.Add(time.Second))
+	if ok, _ := replicas[1].Allow(ctx, "ip:192.0.2.1"); !ok {
+		t.Error("denied after a token was refilled")
+	}
+	if ok, _ := replicas[0].Allow(ctx, "ip:192.0.2.1"); ok {
+		t.Error("allowed twice after one token was refilled")
+	}
+
+	if ttl := mr.TTL("ratelimit:ip:192.0.2.1"); ttl <= 0 || ttl > 2*time.Second {
+		t.Errorf("key TTL = %v, want at most burst intervals", ttl)
+	}
+}
+
+func TestRedisLimiterFailsOpen(t *testing.T) {
+	mr, client := newTestRedis(t)
+	mr.Close()
+
+	l := newRedisLimiter(client, 1, 1, discardLogger)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow(context.Background(), "ip:192.0.2.1"); !ok {
+			t.Fatal("denied while Redis is unreachable")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reloadLogLevelOnHangup sets level from load each time the process gets
+// SIGHUP, until ctx is done, so operators can change verbosity without a
+// restart. An empty level from load leaves the current one; a load error
+// is logged and the level kept. The handler is registered before it
+// returns, so a SIGHUP right after startup isn't fatal.
+func reloadLogLevelOnHangup(ctx context.Context, logger *slog.Logger, level *slog.LevelVar, load func() (string, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				levelStr, err := load()
+				if err != nil {
+					logger.Error("reloading log level failed", "error", err)
+					continue
+				}
+				if levelStr == "" {
+					continue
+				}
+				old := level.Level()
+				level.Set(parseLogLevel(levelStr))
+				logOperatorChange(logger, "log level reloaded", "from", old, "to", level.Level())
+			}
+		}
+	}()
+}
+
+// cycleLogLevelOnSignal steps level through warn, info and debug and back
+// to warn each time the process gets SIGUSR1, until ctx is done, so an
+// operator can turn up verbosity during an incident without editing
+// config:
+//
+//	kill -USR1 $(pidof server)
+//
+// Like reloadLogLevelOnHangup, the handler is registered before it
+// returns.
+func cycleLogLevelOnSignal(ctx context.Context, logger *slog.Logger, level *slog.LevelVar) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(usr1)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-usr1:
+				old := level.Level()
+				level.Set(nextLogLevel(old))
+				logOperatorChange(logger, "log level cycled", "from", old, "to", level.Level())
+			}
+		}
+	}()
+}
+
+// nextLogLevel returns the level after l in the warn, info, debug cycle.
+// Error, and anything else above info, moves to info like warn does.
+func nextLogLevel(l slog.Level) slog.Level {
+	switch {
+	case l <= slog.LevelDebug:
+		return slog.LevelWarn
+	case l <= slog.LevelInfo:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// logOperatorChange records a change an operator made at runtime, such as
+// a signal toggling a setting. It logs at Warn, so the change shows up even
+// when the log level has been turned down to warn.
+func logOperatorChange(logger *slog.Logger, msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestReloadLogLevelOnHangup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, level, _, _ := setupLogger(logOptions{Level: "warn"})
+	reloadLogLevelOnHangup(ctx, discardLogger, level, func() (string, error) { return "debug", nil })
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("sending SIGHUP: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for level.Level() != slog.LevelDebug {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %v after SIGHUP, want DEBUG", level.Level())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCycleLogLevelOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, level, _, _ := setupLogger(logOptions{Level: "warn"})
+	cycleLogLevelOnSignal(ctx, discardLogger, level)
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []slog.Level{slog.LevelInfo, slog.LevelDebug, slog.LevelWarn} {
+		if err := self.Signal(syscall.SIGUSR1); err != nil {
+			t.Skipf("sending SIGUSR1: %v", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for level.Level() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("level = %v after SIGUSR1, want %v", level.Level(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestNextLogLevel(t *testing.T) {
+	tests := []struct{ from, want slog.Level }{
+		{slog.LevelWarn, slog.LevelInfo},
+		{slog.LevelInfo, slog.LevelDebug},
+		{slog.LevelDebug, slog.LevelWarn},
+		{slog.LevelError, slog.LevelInfo},
+	}
+	for _, tt := range tests {
+		if got := nextLogLevel(tt.from); got != tt.want {
+			t.Errorf("nextLogLevel(%v) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestConfigFileLogLevel(t *testing.T) {
+	file := writeConfigFile(t, "config.yaml", "log-level: info\n")
+
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		edit string
+		want string
+	}{
+		{name: "no config file", want: ""},
+		{name: "file edited", args: []string{"--config", file}, edit: "log-level: debug\n", want: "debug"},
+		{name: "key removed", args: []string{"--config", file}, edit: "addr: \":8080\"\n", want: ""},
+		{name: "flag wins", args: []string{"--config", file, "--log-level", "error"}, edit: "log-level: debug\n", want: "error"},
+		{name: "env wins", env: "error", args: []string{"--config", file}, edit: "log-level: debug\n", want: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("LOG_LEVEL", tt.env)
+			}
+			if err := os.WriteFile(file, []byte("log-level: info\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			runServerCommand(t, tt.args, func(c *cli.Context) error {
+				if tt.edit != "" {
+					if err := os.WriteFile(file, []byte(tt.edit), 0o600); err != nil {
+						return err
+					}
+				}
+				var err error
+				got, err = configFileLogLevel(c)
+				return err
+			})
+			if got != tt.want {
+				t.Errorf("configFileLogLevel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"{{cookiecutter.go_mod}}/internal/backoff"
+)
+
+// retryOptions configures retryTransport. The wait doubles after each
+// failed attempt, up to MaxInterval, with jitter so instances don't retry
+// in lockstep.
+type retryOptions struct {
+	MaxAttempts  int // including the first; 1 disables retries
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+	// RetryStatuses are the response codes worth retrying. Connection
+	// errors are always retried.
+	RetryStatuses []int
+}
+
+func defaultRetryOptions() retryOptions {
+	return retryOptions{
+		MaxAttempts:  3,
+		BaseInterval: 100 * time.Millisecond,
+		MaxInterval:  5 * time.Second,
+		RetryStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// retryTransport retries requests that failed to connect or got one of
+// Options.RetryStatuses, waiting per Options or the response's Retry-After,
+// whichever is longer. A Retry-After beyond Options.MaxInterval isn't
+// waited out: the response is returned as is. Only requests that are safe
+// to repeat are retried: idempotent methods and requests carrying an
+// Idempotency-Key, whose body can be replayed (http.NewRequest sets GetBody
+// for the usual body types). Waits end early when the request's context is
+// done.
+type retryTransport struct {
+	Base    http.RoundTripper // http.DefaultTransport if nil
+	Options retryOptions
+
+	sleep func(ctx context.Context, d time.Duration) error // backoff.Sleep if nil; replaced in tests
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	sleep := t.sleep
+	if sleep == nil {
+		sleep = backoff.Sleep
+	}
+	if !retryable(req) {
+		return base.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		try := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = req.Clone(req.Context())
+			try.Body = body
+		}
+
+		resp, err := base.RoundTrip(try)
+		if attempt >= t.Options.MaxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		wait := backoff.Wait(attempt, t.Options.BaseInterval, t.Options.MaxInterval)
+		if err == nil {
+			if !slices.Contains(t.Options.RetryStatuses, resp.StatusCode) {
+				return resp, nil
+			}
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				if after > t.Options.MaxInterval {
+					return resp, nil
+				}
+				wait = max(wait, after)
+			}
+			// Drain a little so the connection can be reused.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether req is safe to send more than once.
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryAfter parses a Retry-After header, in seconds or an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
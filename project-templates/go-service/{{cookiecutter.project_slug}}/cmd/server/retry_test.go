@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyUpstream fails its first failures requests with status, then
+// answers 200 with the request body.
+func flakyUpstream(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// recordSleeps replaces retryTransport's sleep with one that returns at
+// once, recording the waits.
+func recordSleeps(rt *retryTransport) *[]time.Duration {
+	var waits []time.Duration
+	rt.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return &waits
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		header     http.Header
+		failures   int32
+		status     int
+		wantStatus int
+		wantHits   int32
+	}{
+		{name: "get recovers", method: http.MethodGet, failures: 2, status: http.StatusServiceUnavailable, wantStatus: http.StatusOK, wantHits: 3},
+		{name: "put body replayed", method: http.MethodPut, body: "payload", failures: 1, status: http.StatusBadGateway, wantStatus: http.StatusOK, wantHits: 2},
+		{name: "attempts exhausted", method: http.MethodGet, failures: 5, status: http.StatusServiceUnavailable, wantStatus: http.StatusServiceUnavailable, wantHits: 3},
+		{name: "post not retried", method: http.MethodPost, body: "payload", failures: 1, status: http.StatusServiceUnavailable, wantStatus: http.StatusServiceUnavailable, wantHits: 1},
+		{
+			name:   "post with idempotency key",
+			method: http.MethodPost, body: "payload", header: http.Header{"Idempotency-Key": {"k1"}},
+			failures: 1, status: http.StatusServiceUnavailable, wantStatus: http.StatusOK, wantHits: 2,
+		},
+		{name: "client error not retried", method: http.MethodGet, failures: 1, status: http.StatusNotFound, wantStatus: http.StatusNotFound, wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream, hits := flakyUpstream(t, tt.failures, tt.status, nil)
+			rt := &retryTransport{Options: defaultRetryOptions()}
+			recordSleeps(rt)
+
+			req, err := http.NewRequest(tt.method, upstream.URL, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			resp, err := (&http.Client{Transport: rt}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus || hits.Load() != tt.wantHits {
+				t.Errorf("status %d after %d hits, want %d after %d", resp.StatusCode, hits.Load(), tt.wantStatus, tt.wantHits)
+			}
+			if resp.StatusCode == http.StatusOK && string(body) != tt.body {
+				t.Errorf("upstream got body %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		wantStatus int
+		wantWaits  []time.Duration
+	}{
+		{name: "honored", retryAfter: "2", wantStatus: http.StatusOK, wantWaits: []time.Duration{2 * time.Second}},
+		{name: "beyond max interval", retryAfter: "60", wantStatus: http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream, _ := flakyUpstream(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {tt.retryAfter}})
+			rt := &retryTransport{Options: defaultRetryOptions()}
+			waits := recordSleeps(rt)
+
+			resp, err := (&http.Client{Transport: rt}).Get(upstream.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(*waits) != len(tt.wantWaits) || (len(tt.wantWaits) > 0 && (*waits)[0] != tt.wantWaits[0]) {
+				t.Errorf("waits = %v, want %v", *waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+// roundTripFunc adapts a func to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRetryTransportConnectionErrors(t *testing.T) {
+	refused := errors.New("connect: connection refused")
+	var attempts int
+	rt := &retryTransport{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, refused
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		}),
+		Options: defaultRetryOptions(),
+	}
+	waits := recordSleeps(rt)
+
+	resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://upstream/", nil))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("RoundTrip = %v, %v, want 200 after a retry", resp, err)
+	}
+	if attempts != 2 || len(*waits) != 1 {
+		t.Errorf("attempts = %d after %d waits, want 2 after 1", attempts, len(*waits))
+	}
+	if w := (*waits)[0]; w < 50*time.Millisecond || w > 100*time.Millisecond {
+		t.Errorf("first wait = %v, want between 50ms and 100ms", w)
+	}
+}
+
+func TestRetryTransportCancelled(t *testing.T) {
+	upstream, hits := flakyUpstream(t, 5, http.StatusServiceUnavailable, nil)
+	rt := &retryTransport{Options: defaultRetryOptions()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rt.Options.BaseInterval = time.Minute
+	rt.Options.MaxInterval = time.Minute
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+
+	start := time.Now()
+	_, err := (&http.Client{Transport: rt}).Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second || hits.Load() != 1 {
+		t.Errorf("returned after %v and %d hits, want promptly after 1", elapsed, hits.Load())
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// routerDeps is what buildRouter needs to construct handlers and their
+// middleware.
+type routerDeps struct {
+	cfg          serverConfig
+	logger       *slog.Logger
+	logLevel     *slog.LevelVar // reported by /debug/config; nil reports cfg's
+	registry     *prometheus.Registry
+	health       *healthRegistry
+	shuttingDown *atomic.Bool
+	jwtOpts      jwtAuthOptions
+	metricsAllow []netip.Prefix
+	rateLimiter  limiter       // nil disables rate limiting
+	tokens       *tokenIssuer  // nil disables the token endpoints
+	auth         Authenticator // nil disables POST /login
+	apiKeys      KeyStore      // nil disables X-API-Key auth
+	audit        *slog.Logger  // nil disables audit records
+	// outbound is for handlers that call other services; it attaches an
+	// OAuth2 token when --oauth-token-url is set.
+	outbound *http.Client
+}
+
+// buildRouter registers every route with its own adapter chain. Middleware
+// that applies to all requests (security headers, gzip, CORS) is added by
+// the caller around the returned mux. It registers metrics with
+// deps.registry, so call it once per registry.
+func buildRouter(deps routerDeps) *http.ServeMux {
+	return buildRoutes(deps).mux
+}
+
+// buildRoutes is buildRouter, returning the routes with their
+// documentation.
+func buildRoutes(deps routerDeps) routeMux {
+	base := DefaultChain(deps.logger)
+	if deps.cfg.LogBodyBytes > 0 {
+		opts := bodyLoggingOptions{MaxBytes: deps.cfg.LogBodyBytes}
+		if deps.cfg.Log.Redact {
+			opts.Redact = redactJSONFields(deps.cfg.Log.RedactKeys)
+		}
+		base = base.Use(stageLogging, withBodyLogging(opts))
+	}
+	// Cheap, frequently polled endpoints skip tracing.
+	untraced := MiddlewareChain{}.
+		Use(stageRequestID, withRequestID(uuid.NewString)).
+		Use(stageRecovery, withRecovery(deps.logger)).
+		Use(stageLogging, withLogging(deps.logger, accessLogLevel))
+	// Routes get request-timeout unless --route-timeouts overrides their
+	// pattern (validated by serverConfig.Validate).
+	overrides, _ := parseRouteTimeouts(deps.cfg.RouteTimeouts)
+	timeouts := routeTimeouts{Default: deps.cfg.RequestTimeout, Overrides: overrides, Write: deps.cfg.Timeouts.Write}
+	metricsOpts := metricsOptions{
+		Buckets: deps.cfg.LatencyBuckets,
+		Exclude: deps.cfg.MetricsExcludeRoutes,
+		SLO:     deps.cfg.SLOLatency,
+	}
+	timed := func(pattern string) MiddlewareChain {
+		return base.
+			Use(stageMetrics, withMetrics(deps.registry, metricsOpts)).
+			Use(stageDisconnect, withClientDisconnect()).
+			Use(stageTimeout, timeouts.For(pattern))
+	}
+	// Protected routes take bearer tokens, Basic credentials when
+	// --basic-auth-users is set (validated by serverConfig.Validate) and
+	// API keys when deps.apiKeys is set, or nothing under --auth-disabled.
+	jwtOpts := deps.jwtOpts
+	jwtOpts.Metrics = newAuthMetrics(deps.registry)
+	authenticate := withJWTAuth(jwtOpts)
+	authSchemes := []string{"bearerAuth"}
+	if basicUsers, _ := parseBasicAuthUsers(deps.cfg.BasicAuthUsers); len(basicUsers) > 0 {
+		authenticate = withBasicOrJWTAuth(withBasicAuth(basicUsers), authenticate)
+		authSchemes = append(authSchemes, "basicAuth")
+	}
+	if deps.apiKeys != nil {
+		authenticate = withAPIKeyOrAuth(withAPIKey(deps.apiKeys), authenticate)
+		authSchemes = append(authSchemes, "apiKey")
+	}
+	if deps.cfg.AuthDisabled {
+		authenticate, authSchemes = withoutAuth(), nil
+	}
+
+	// Every route is documented for GET /openapi.json as it's registered.
+	spec := newAPISpec(authSchemes...)
+	mux := newRouteMux(spec)
+
+	// Public endpoints
+	mux.Handle("GET /healthz", routeDoc{Summary: "Report readiness with the status of each health check"},
+		timed("GET /healthz").Then(handleHealth(deps.health, deps.shuttingDown)))
+	mux.Handle("GET /livez", routeDoc{Summary: "Report that the process is up"},
+		untraced.Then(handleLive()))
+	mux.Handle("GET /readyz", routeDoc{Summary: "Report readiness with the status of each health check"},
+		timed("GET /readyz").Then(handleHealth(deps.health, deps.shuttingDown)))
+	mux.Handle("GET /version", routeDoc{Summary: "Report build information", Response: buildInfo{}},
+		untraced.Then(handleVersion()))
+
+	// Streaming: no withTimeout, which buffers responses.
+	mux.Handle("GET /events", routeDoc{Summary: "Stream progress as server-sent events"},
+		base.Then(handleEvents(time.Second)))
+
+	mux.Handle("GET /metrics", routeDoc{Summary: "Serve Prometheus metrics"},
+		handleMetrics(deps.registry, deps.cfg.MetricsAuthToken, deps.metricsAllow))
+
+	// Token endpoints authenticate with credentials in the body, not a
+	// bearer token, so they're rate limited by client IP.
+	tokenChain := func(pattern string) MiddlewareChain {
+		return timed(pattern).
+			Use(stageRateLimit, withRateLimit(deps.rateLimiter)).
+			Use(stageContentType, withRequireContentType("application/json"))
+	}
+	if deps.tokens != nil && deps.auth != nil {
+		mux.Handle("POST /login", routeDoc{
+			Summary:  "Exchange a username and password for tokens",
+			Request:  loginRequest{},
+			Response: tokenPair{},
+		}, tokenChain("POST /login").Then(handleLogin(deps.auth, deps.tokens)))
+	}
+	if deps.tokens != nil && deps.tokens.refreshes() {
+		mux.Handle("POST /token/refresh", routeDoc{
+			Summary:  "Exchange a refresh token for a new token pair",
+			Request:  refreshRequest{},
+			Response: tokenPair{},
+		}, tokenChain("POST /token/refresh").Then(handleTokenRefresh(deps.tokens)))
+	}
+
+	// Versioned API. Add a newAPIGroup(mux, "v2") alongside v1 to change a
+	// route's contract; unversioned paths redirect to defaultAPIVersion.
+	v1 := newAPIGroup(mux, "v1")
+
+	// Protected endpoints
+	v1.Handle("GET /whoami", routeDoc{
+		Summary:  "Return the caller's claims",
+		Response: whoamiResponse{},
+		Auth:     true,
+	}, base.
+		Use(stageMetrics, withMetrics(deps.registry, metricsOpts)).
+		Use(stageDisconnect, withClientDisconnect()).
+		Use(stageTimeout, timeouts.For("GET /v1/whoami")).
+		Use(stageAuth, authenticate).
+		Use(stageAuth, withTenant(deps.cfg.MetricsTenants)).
+		Use(stageAuth, withAudit(deps.audit)).
+		Use(stageRateLimit, withRateLimit(deps.rateLimiter)).
+		Then(handleWhoami(whoamiOptions{Claims: deps.cfg.WhoamiClaims})))
+
+	// Admins only: the config is masked, but still maps the deployment.
+	mux.Handle("GET /debug/config", routeDoc{
+		Summary:  "Return the effective configuration with secrets masked (admin scope)",
+		Response: map[string]any{},
+		Auth:     true,
+	}, timed("GET /debug/config").
+		Use(stageAuth, authenticate).
+		Use(stageAuth, withAudit(deps.audit)).
+		Use(stageAuthz, withRequireScope("admin")).
+		Then(handleDebugConfig(deps.cfg, deps.logLevel)))
+
+	mux.Handle("GET /debug/health", routeDoc{
+		Summary:  "Report each health check with its error (admin scope)",
+		Response: map[string]any{},
+		Auth:     true,
+	}, timed("GET /debug/health").
+		Use(stageAuth, authenticate).
+		Use(stageAuth, withAudit(deps.audit)).
+		Use(stageAuthz, withRequireScope("admin")).
+		Then(handleHealthDetails(deps.health)))
+
+	if deps.cfg.EnablePprof {
+		// No withTimeout: CPU profiles and traces run for ?seconds=N.
+		// Admins only: profiles cost CPU and goroutine dumps expose
+		// internals.
+		registerPprof(mux, untraced.
+			Use(stageAuth, authenticate).
+			Use(stageAuth, withAudit(deps.audit)).
+			Use(stageAuthz, withRequireScope("admin")).
+			Adapters()...)
+	}
+
+	mux.Handle("GET /openapi.json", routeDoc{Summary: "Return this OpenAPI document", Response: map[string]any{}},
+		untraced.Then(handleOpenAPI(spec)))
+
+	return mux
+}
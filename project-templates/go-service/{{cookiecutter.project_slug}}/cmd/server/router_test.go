@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testRouter builds the router with test dependencies and cfg applied.
+func testRouter(t *testing.T, modify func(*serverConfig)) http.Handler {
+	t.Helper()
+	cfg := serverConfig{
+		RequestTimeout: time.Second,
+		LatencyBuckets: prometheus.DefBuckets,
+	}
+	if modify != nil {
+		modify(&cfg)
+	}
+	return buildRouter(routerDeps{
+		cfg:          cfg,
+		logger:       discardLogger,
+		registry:     prometheus.NewRegistry(),
+		health:       newHealthRegistry(),
+		shuttingDown: new(atomic.Bool),
+		jwtOpts:      jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))},
+	})
+}
+
+func TestBuildRouter(t *testing.T) {
+	h := testRouter(t, nil)
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{name: "livez", req: httptest.NewRequest(http.MethodGet, "/livez", nil), wantStatus: http.StatusOK},
+		{name: "readyz", req: httptest.NewRequest(http.MethodGet, "/readyz", nil), wantStatus: http.StatusOK},
+		{name: "version", req: httptest.NewRequest(http.MethodGet, "/version", nil), wantStatus: http.StatusOK},
+		{name: "metrics", req: httptest.NewRequest(http.MethodGet, "/metrics", nil), wantStatus: http.StatusOK},
+		{name: "HEAD matches GET route", req: httptest.NewRequest(http.MethodHead, "/livez", nil), wantStatus: http.StatusOK},
+		{name: "wrong method", req: httptest.NewRequest(http.MethodPost, "/livez", nil), wantStatus: http.StatusMethodNotAllowed},
+		{name: "unknown route", req: httptest.NewRequest(http.MethodGet, "/nope", nil), wantStatus: http.StatusNotFound},
+		{name: "whoami without token", req: httptest.NewRequest(http.MethodGet, "/v1/whoami", nil), wantStatus: http.StatusUnauthorized},
+		{name: "whoami", req: authedRequest(t, http.MethodGet, "/v1/whoami", jwt.MapClaims{"sub": "u1"}), wantStatus: http.StatusOK},
+		{name: "unversioned whoami redirects", req: httptest.NewRequest(http.MethodGet, "/whoami", nil), wantStatus: http.StatusPermanentRedirect},
+		{name: "pprof disabled", req: httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil), wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, tt.req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestBuildRouterPprof(t *testing.T) {
+	h := testRouter(t, func(cfg *serverConfig) { cfg.EnablePprof = true })
+
+	rec := serve(h, authedRequest(t, http.MethodGet, "/debug/pprof/", jwt.MapClaims{"sub": "ops", "scope": "admin"}))
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin status = %d, want 200", rec.Code)
+	}
+	rec = serve(h, authedRequest(t, http.MethodGet, "/debug/pprof/goroutine", jwt.MapClaims{"sub": "u1"}))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin status = %d, want 403", rec.Code)
+	}
+}
+
+func TestWhoamiOmitsUnlistedClaims(t *testing.T) {
+	claims := jwt.MapClaims{
+		"sub":             "u1",
+		"email":           "u1@example.com",
+		"scope":           "read",
+		"internal_secret": "s3cr3t",
+	}
+
+	tests := []struct {
+		name   string
+		allow  []string
+		wantIn []string
+	}{
+		{name: "defaults", wantIn: []string{"sub", "email", "scope"}},
+		{name: "configured", allow: []string{"sub"}, wantIn: []string{"sub"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testRouter(t, func(cfg *serverConfig) { cfg.WhoamiClaims = tt.allow })
+
+			rec := serve(h, authedRequest(t, http.MethodGet, "/v1/whoami", claims))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var body struct {
+				Claims map[string]interface{} `json:"claims"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Claims) != len(tt.wantIn) {
+				t.Errorf("claims = %v, want only %v", body.Claims, tt.wantIn)
+			}
+			for _, key := range tt.wantIn {
+				if body.Claims[key] != claims[key] {
+					t.Errorf("claim %s = %v, want %v", key, body.Claims[key], claims[key])
+				}
+			}
+			if strings.Contains(rec.Body.String(), "s3cr3t") {
+				t.Errorf("response leaks internal_secret: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	h := testRouter(t, func(cfg *serverConfig) { cfg.AuthDisabled = true })
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/v1/whoami", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body whoamiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Claims["sub"] != devSubject {
+		t.Errorf("sub = %v, want %q", body.Claims["sub"], devSubject)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// securityHeadersOptions holds the value for each header withSecurityHeaders
+// sets. An empty value (or zero HSTSMaxAge) leaves that header unset.
+type securityHeadersOptions struct {
+	ContentTypeOptions    string
+	FrameOptions          string
+	ReferrerPolicy        string
+	ContentSecurityPolicy string
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+}
+
+// defaultSecurityHeaders suits a JSON API: nothing may be framed, sniffed,
+// or loaded from the responses.
+func defaultSecurityHeaders() securityHeadersOptions {
+	return securityHeadersOptions{
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+	}
+}
+
+func withSecurityHeaders(opts securityHeadersOptions) adapter {
+	headers := map[string]string{
+		"X-Content-Type-Options":  opts.ContentTypeOptions,
+		"X-Frame-Options":         opts.FrameOptions,
+		"Referrer-Policy":         opts.ReferrerPolicy,
+		"Content-Security-Policy": opts.ContentSecurityPolicy,
+	}
+	if opts.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge.Seconds()))
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+	for k, v := range headers {
+		if v == "" {
+			delete(headers, k)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range headers {
+				w.Header().Set(k, v)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
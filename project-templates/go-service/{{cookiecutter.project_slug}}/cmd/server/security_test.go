@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		h := withSecurityHeaders(defaultSecurityHeaders())(okHandler)
+		rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+		want := map[string]string{
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Referrer-Policy":           "no-referrer",
+			"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+			"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		}
+		for k, v := range want {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		opts := defaultSecurityHeaders()
+		opts.ContentSecurityPolicy = ""
+		opts.HSTSMaxAge = 0
+		rec := serve(withSecurityHeaders(opts)(okHandler), httptest.NewRequest(http.MethodGet, "/", nil))
+		for _, k := range []string{"Content-Security-Policy", "Strict-Transport-Security"} {
+			if _, ok := rec.Header()[k]; ok {
+				t.Errorf("%s set to %q, want it unset", k, rec.Header().Get(k))
+			}
+		}
+		if got := rec.Header().Get("X-Frame-Options"); got != "DENY" {
+			t.Errorf("X-Frame-Options = %q, want DENY", got)
+		}
+	})
+}
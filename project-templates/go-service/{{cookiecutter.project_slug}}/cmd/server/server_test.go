@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	timeouts := serverTimeouts{
+		ReadHeader: 1 * time.Second,
+		Read:       2 * time.Second,
+		Write:      3 * time.Second,
+		Idle:       4 * time.Second,
+	}
+	srv := newHTTPServer(":9999", okHandler, timeouts)
+
+	if srv.Addr != ":9999" {
+		t.Errorf("Addr = %q, want :9999", srv.Addr)
+	}
+	got := serverTimeouts{
+		ReadHeader: srv.ReadHeaderTimeout,
+		Read:       srv.ReadTimeout,
+		Write:      srv.WriteTimeout,
+		Idle:       srv.IdleTimeout,
+	}
+	if got != timeouts {
+		t.Errorf("timeouts = %+v, want %+v", got, timeouts)
+	}
+}
+
+func TestH2C(t *testing.T) {
+	h := withSecurityHeaders(defaultSecurityHeaders())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.Protocols = h2cProtocols()
+	srv.Start()
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		protocols func(*http.Protocols)
+		want      string
+	}{
+		{name: "h2c prior knowledge", protocols: func(p *http.Protocols) { p.SetUnencryptedHTTP2(true) }, want: "HTTP/2.0"},
+		{name: "http/1.1", protocols: func(p *http.Protocols) { p.SetHTTP1(true) }, want: "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var protocols http.Protocols
+			tt.protocols(&protocols)
+			client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("served over %s, want %s", body, tt.want)
+			}
+			// Middleware wraps the handler whichever protocol is used.
+			if resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+				t.Errorf("security headers missing over %s", body)
+			}
+		})
+	}
+}
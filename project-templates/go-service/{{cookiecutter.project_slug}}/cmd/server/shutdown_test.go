@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHealthFailsWhileShuttingDown(t *testing.T) {
+	var shuttingDown atomic.Bool
+	h := handleHealth(newHealthRegistry(), &shuttingDown)
+
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/healthz", nil)); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	shuttingDown.Store(true)
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/healthz", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status while shutting down = %d, want 503", rec.Code)
+	}
+}
+
+func TestMarkNotReadyWaitsWhileFailingHealth(t *testing.T) {
+	var shuttingDown atomic.Bool
+	h := handleHealth(newHealthRegistry(), &shuttingDown)
+	const delay = 50 * time.Millisecond
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		markNotReady(context.Background(), &shuttingDown, delay)
+		close(done)
+	}()
+
+	// The flag flips before the delay, so probes fail during it.
+	for !shuttingDown.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("markNotReady returned before the delay")
+	default:
+	}
+	if rec := serve(h, httptest.NewRequest(http.MethodGet, "/readyz", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status during delay = %d, want 503", rec.Code)
+	}
+
+	<-done
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("markNotReady returned after %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestMarkNotReadyStopsWhenContextDone(t *testing.T) {
+	var shuttingDown atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		markNotReady(ctx, &shuttingDown, time.Hour)
+		close(done)
+	}()
+
+	for !shuttingDown.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("markNotReady kept waiting after ctx was cancelled")
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	cfg := serverConfig{Addr: "unix:" + path, ShutdownTimeout: 5 * time.Second}
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := newHTTPServer(cfg.Addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		io.WriteString(w, "finished after shutdown began")
+	}), defaultServerTimeouts())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var shuttingDown atomic.Bool
+	stopped := make(chan error, 1)
+	go func() { stopped <- runHTTPServer(ctx, cfg, discardLogger, server, nil, &shuttingDown) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			// Retry until the listener is up.
+			for {
+				conn, err := (&net.Dialer{}).DialContext(ctx, "unix", path)
+				if err == nil || ctx.Err() != nil {
+					return conn, err
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		},
+	}}
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := client.Get("http://app/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{resp.StatusCode, string(body), err}
+	}()
+	<-entered
+
+	cancel()
+	for !shuttingDown.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-stopped:
+		t.Fatalf("runHTTPServer returned %v with a request in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	start := time.Now()
+	close(release)
+	got := <-responses
+	if got.err != nil || got.status != http.StatusOK || got.body != "finished after shutdown began" {
+		t.Errorf("in-flight request got %d %q, %v; want the full 200 response", got.status, got.body, got.err)
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("runHTTPServer = %v, want nil after draining", err)
+		}
+	case <-time.After(cfg.ShutdownTimeout):
+		t.Fatal("runHTTPServer did not return within the shutdown timeout")
+	}
+	if elapsed := time.Since(start); elapsed > cfg.ShutdownTimeout {
+		t.Errorf("draining took %v, want under %v", elapsed, cfg.ShutdownTimeout)
+	}
+}
+
+func TestSecondSignalSkipsShutdownDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	cfg := serverConfig{Addr: "unix:" + path, ShutdownDelay: time.Hour, ShutdownTimeout: 5 * time.Second}
+	server := newHTTPServer(cfg.Addr, okHandler, defaultServerTimeouts())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var shuttingDown atomic.Bool
+	stopped := make(chan error, 1)
+	go func() { stopped <- runHTTPServer(ctx, cfg, discardLogger, server, nil, &shuttingDown) }()
+
+	// The first signal is the cancelled ctx. shuttingDown flips once the
+	// delay has started listening for the second.
+	cancel()
+	for !shuttingDown.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("runHTTPServer = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runHTTPServer kept waiting out the delay after a second signal")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeSSE writes one Server-Sent Event and flushes it to the client. The
+// first call sets the event-stream headers. Multi-line data is split across
+// data: fields as the spec requires. It fails if no writer in the chain can
+// flush, since an unflushed stream never reaches the browser.
+func writeSSE(w http.ResponseWriter, event, data string) error {
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		// Stop nginx-style proxies from buffering the stream.
+		h.Set("X-Accel-Buffering", "no")
+	}
+
+	var b strings.Builder
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// handleEvents is an example SSE endpoint that reports progress every
+// interval until done or the client disconnects. Don't wrap streaming
+// routes in withTimeout, which buffers the response.
+func handleEvents(interval time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server's WriteTimeout would cut long streams off; lift it for
+		// this response. Writers that can't set deadlines are fine to ignore.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for percent := 0; percent <= 100; percent += 10 {
+			data, _ := json.Marshal(map[string]int{"percent": percent})
+			if err := writeSSE(w, "progress", string(data)); err != nil {
+				LoggerFromContext(r.Context()).WarnContext(r.Context(), "event stream write failed", "error", err)
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
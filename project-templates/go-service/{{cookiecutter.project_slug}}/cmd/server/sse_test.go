@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSSEMultiline(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := writeSSE(rec, "note", "line one\nline two"); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	want := "event: note\ndata: line one\ndata: line two\n\n"
+	if rec.Body.String() != want || !rec.Flushed {
+		t.Errorf("body = %q (flushed %v), want %q flushed", rec.Body.String(), rec.Flushed, want)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	// Run behind the middleware that wraps the writer to check flushes get
+	// through it.
+	h := adaptHandler(handleEvents(time.Millisecond),
+		withGzip(),
+		withLogging(discardLogger, accessLogLevel),
+	)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(events) < 2 {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	want := []string{`{"percent":0}`, `{"percent":10}`}
+	if strings.Join(events, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// statusClientClosedRequest is the non-standard status nginx logs when the
+// client disconnects before the response is ready. Nobody receives it; it
+// keeps those requests apart from server errors in logs and metrics.
+const statusClientClosedRequest = 499
+
+// callTemporal runs call, typically a ClientProvider.ExecuteWorkflow or
+// SignalWithStartWorkflow, with the request's context, so a client
+// disconnect or the request deadline aborts the downstream call instead of
+// leaving it running for nobody. If call fails it writes the error
+// response and returns false:
+//
+//	var run client.WorkflowRun
+//	if !callTemporal(w, r, func(ctx context.Context) (err error) {
+//		run, err = clients.ExecuteWorkflow(ctx, opts, worker.GreetingWorkflow, name)
+//		return err
+//	}) {
+//		return
+//	}
+//
+// A cancelled request is answered with 499 and a deadline with 504; other
+// errors are logged and answered with 502, as the failure is upstream.
+func callTemporal(w http.ResponseWriter, r *http.Request, call func(ctx context.Context) error) bool {
+	err := call(r.Context())
+	if err == nil {
+		return true
+	}
+
+	// The SDK reports cancellation as gRPC errors that don't wrap the
+	// context's, so ask the context why the call ended.
+	ctxErr := r.Context().Err()
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(ctxErr, context.Canceled):
+		LoggerFromContext(r.Context()).InfoContext(r.Context(), "client went away during temporal call")
+		p := newProblem(statusClientClosedRequest, "request cancelled by the client")
+		p.Title = "Client Closed Request"
+		writeProblem(w, r, p)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctxErr, context.DeadlineExceeded):
+		writeProblem(w, r, newProblem(http.StatusGatewayTimeout, "temporal call timed out"))
+	default:
+		LoggerFromContext(r.Context()).ErrorContext(r.Context(), "temporal call failed", "error", err)
+		writeProblem(w, r, newProblem(http.StatusBadGateway, "temporal call failed"))
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingCall stands in for a Temporal call to an unresponsive frontend:
+// it blocks until ctx is done and then fails the way the SDK does, with an
+// error that doesn't wrap ctx's.
+func blockingCall(aborted chan<- struct{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		close(aborted)
+		return errors.New("rpc error: code = Canceled desc = context canceled")
+	}
+}
+
+func TestCallTemporalClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+	aborted := make(chan struct{})
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		if callTemporal(rec, req, blockingCall(aborted)) {
+			t.Error("callTemporal = true, want false")
+		}
+		done <- rec
+	}()
+	cancel()
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("downstream call not aborted by the cancelled request")
+	}
+	rec := <-done
+	if rec.Code != statusClientClosedRequest {
+		t.Errorf("status = %d, want %d", rec.Code, statusClientClosedRequest)
+	}
+}
+
+func TestCallTemporal(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		err        error
+		wantOK     bool
+		wantStatus int
+	}{
+		{name: "success", ctx: context.Background(), wantOK: true, wantStatus: http.StatusOK},
+		{name: "deadline", ctx: expired, err: errors.New("rpc error: code = DeadlineExceeded"), wantStatus: http.StatusGatewayTimeout},
+		{name: "wrapped deadline", ctx: context.Background(), err: context.DeadlineExceeded, wantStatus: http.StatusGatewayTimeout},
+		{name: "upstream failure", ctx: context.Background(), err: errors.New("workflow already started"), wantStatus: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(tt.ctx)
+			rec := httptest.NewRecorder()
+
+			ok := callTemporal(rec, req, func(context.Context) error { return tt.err })
+
+			if ok != tt.wantOK {
+				t.Errorf("callTemporal = %v, want %v", ok, tt.wantOK)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
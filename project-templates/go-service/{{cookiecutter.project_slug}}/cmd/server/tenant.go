@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync/atomic"
+)
+
+// tenantClaim is the claim withTenant reads the tenant from.
+const tenantClaim = "tenant_id"
+
+// otherTenant is the metric label of tenants missing from the allowlist.
+const otherTenant = "other"
+
+// tenant is the tenant of a request. Label is ID when ID is allowlisted
+// and otherTenant otherwise, so metrics stay bounded however many tenants
+// sign up.
+type tenant struct {
+	ID    string
+	Label string
+}
+
+// tenantSlot carries the tenant out of withTenant to withLogging and
+// withMetrics: they run before auth, so they can't see context values
+// added after them. It's atomic because withTimeout runs handlers on
+// another goroutine that may outlive the request.
+type tenantSlot struct {
+	tenant atomic.Pointer[tenant]
+}
+
+// withTenantSlot returns r with a tenantSlot in its context, reusing one
+// an outer adapter already added.
+func withTenantSlot(r *http.Request) (*http.Request, *tenantSlot) {
+	if slot, ok := r.Context().Value(tenantKey).(*tenantSlot); ok {
+		return r, slot
+	}
+	slot := new(tenantSlot)
+	return r.WithContext(context.WithValue(r.Context(), tenantKey, slot)), slot
+}
+
+// get returns the tenant withTenant found, or the zero tenant.
+func (s *tenantSlot) get() tenant {
+	if t := s.tenant.Load(); t != nil {
+		return *t
+	}
+	return tenant{}
+}
+
+// TenantFromContext returns the tenant ID withTenant found in the claims,
+// or "" if there's none.
+func TenantFromContext(ctx context.Context) string {
+	if slot, ok := ctx.Value(tenantKey).(*tenantSlot); ok {
+		return slot.get().ID
+	}
+	return ""
+}
+
+// withTenant reads the tenant_id claim and records it for
+// TenantFromContext, the request logger and withLogging's access log line
+// (tenant_id) and withMetrics' http_requests_total (tenant label). Only
+// tenants in allow get their own label; the rest share otherTenant. Add it
+// after the auth adapter; requests without the claim pass through as is.
+func withTenant(allow []string) adapter {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, _ := claims.Raw[tenantClaim].(string)
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			t := tenant{ID: id, Label: otherTenant}
+			if slices.Contains(allow, id) {
+				t.Label = id
+			}
+			r, slot := withTenantSlot(r)
+			slot.tenant.Store(&t)
+			logger := LoggerFromContext(r.Context()).With("tenant_id", id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerKey, logger)))
+		})
+	}
+}
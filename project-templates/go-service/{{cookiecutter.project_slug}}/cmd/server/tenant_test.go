@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestTenantMetricLabel(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		wantLabel string
+	}{
+		{name: "allowlisted tenant", claims: jwt.MapClaims{"sub": "u1", "tenant_id": "acme"}, wantLabel: "acme"},
+		{name: "other tenant", claims: jwt.MapClaims{"sub": "u1", "tenant_id": "initech"}, wantLabel: "other"},
+		{name: "no tenant", claims: jwt.MapClaims{"sub": "u1"}, wantLabel: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			h := adaptHandler(okHandler,
+				withMetrics(reg, defaultMetricsOptions()),
+				withTimeout(time.Second),
+				withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}),
+				withTenant([]string{"acme"}),
+			)
+			serve(h, authedRequest(t, http.MethodGet, "/", tt.claims))
+
+			sets := metricLabels(t, reg, "http_requests_total")
+			if len(sets) != 1 || sets[0]["tenant"] != tt.wantLabel {
+				t.Errorf("labels = %v, want one series with tenant %q", sets, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestTenantLogged(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	var fromContext string
+	h := adaptHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromContext = TenantFromContext(r.Context())
+		LoggerFromContext(r.Context()).InfoContext(r.Context(), "handled")
+	}),
+		withLogging(logger, accessLogLevel),
+		withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))}),
+		withTenant(nil),
+	)
+	serve(h, authedRequest(t, http.MethodGet, "/", jwt.MapClaims{"sub": "u1", "tenant_id": "initech"}))
+
+	if fromContext != "initech" {
+		t.Errorf("TenantFromContext = %q, want initech", fromContext)
+	}
+	entries := logEntries(t, &logs)
+	for _, msg := range []string{"handled", "request"} {
+		if got := findLog(t, entries, msg)["tenant_id"]; got != "initech" {
+			t.Errorf("%s log tenant_id = %v, want initech (logs aren't allowlisted)", msg, got)
+		}
+	}
+	if got := TenantFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != "" {
+		t.Errorf("TenantFromContext without withTenant = %q, want empty", got)
+	}
+}
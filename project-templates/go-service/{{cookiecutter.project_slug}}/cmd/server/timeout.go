@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// routeTimeouts picks the withTimeout duration for each route: its
+// override, keyed by the pattern the route is registered with
+// ("POST /v1/reports"), or Default.
+type routeTimeouts struct {
+	Default   time.Duration
+	Overrides map[string]time.Duration
+	// Write is the server's write timeout, which overrides may exceed.
+	Write time.Duration
+}
+
+// For returns the timeout adapter for the route registered as pattern.
+// Give a route exactly one, rather than adding it inside another
+// withTimeout: the shorter deadline would win.
+func (rt routeTimeouts) For(pattern string) adapter {
+	d, ok := rt.Overrides[pattern]
+	if !ok {
+		d = rt.Default
+	}
+	timeout := withTimeout(d)
+	if rt.Write <= 0 || d < rt.Write {
+		return timeout
+	}
+	// The server's write deadline would cut the response off before the
+	// handler's deadline; push it back so the handler gets d and the
+	// response the usual write timeout after that.
+	return func(next http.Handler) http.Handler {
+		h := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + rt.Write))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseRouteTimeouts parses "PATTERN=DURATION" entries, as in
+// --route-timeouts, e.g. "POST /v1/reports=60s".
+func parseRouteTimeouts(entries []string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(entries))
+	for _, e := range entries {
+		i := strings.LastIndex(e, "=")
+		if i < 0 || !strings.Contains(e[:i], "/") {
+			return nil, fmt.Errorf("route timeout %q must be PATTERN=DURATION", e)
+		}
+		d, err := time.ParseDuration(e[i+1:])
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("route timeout %q: duration must be positive", e)
+		}
+		timeouts[e[:i]] = d
+	}
+	return timeouts, nil
+}
+
+// withTimeout bounds how long downstream handlers may run. The handler gets a
+// context with the deadline applied and runs against a buffered writer, as
+// with http.TimeoutHandler; if it hasn't finished by the deadline the client
+// receives a JSON 503 and anything the handler writes afterwards is dropped.
+// Because responses are buffered, don't use it on streaming routes.
+func withTimeout(d time.Duration) adapter {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// Re-panic on the serving goroutine so withRecovery sees it.
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, vv := range tw.header {
+					dst[k] = vv
+				}
+				if tw.code == 0 {
+					tw.code = http.StatusOK
+				}
+				w.WriteHeader(tw.code)
+				w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					writeProblem(w, r, problemUnavailable("request timed out"))
+				}
+			}
+		})
+	}
+}
+
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// secureTLSConfig requires TLS 1.2+ and, for 1.2, restricts cipher suites to
+// AEAD ciphers with forward secrecy. TLS 1.3 suites aren't configurable.
+func secureTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// handleHTTPSRedirect redirects every request to the same host and path on
+// the HTTPS listener at tlsAddr.
+func handleHTTPSRedirect(tlsAddr string) http.Handler {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
+
+// requireHTTPSOptions configures withRequireHTTPS.
+type requireHTTPSOptions struct {
+	// TrustedProxies are the peers whose X-Forwarded-Proto is believed, as
+	// for withClientIP.
+	TrustedProxies []netip.Prefix
+	// Redirect sends cleartext requests to the HTTPS URL instead of
+	// rejecting them with 400.
+	Redirect bool
+	// Exempt paths are served over cleartext too, for probes and scrapers
+	// that reach the pod directly.
+	Exempt []string
+}
+
+// withRequireHTTPS refuses requests that didn't arrive over HTTPS, either
+// on a TLS listener or, behind a TLS-terminating proxy, as reported by a
+// trusted proxy's X-Forwarded-Proto. Redirecting suits browsers; API
+// clients that sent credentials in cleartext are better off rejected, so
+// they notice.
+func withRequireHTTPS(opts requireHTTPSOptions) adapter {
+	redirect := handleHTTPSRedirect("")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHTTPS(r, opts.TrustedProxies) || slices.Contains(opts.Exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if opts.Redirect {
+				redirect.ServeHTTP(w, r)
+				return
+			}
+			writeProblem(w, r, problemBadRequest("HTTPS required"))
+		})
+	}
+}
+
+// isHTTPS reports whether r arrived over TLS. X-Forwarded-Proto is only
+// believed from a trusted peer; its last entry is the one that peer set.
+func isHTTPS(r *http.Request, trusted []netip.Prefix) bool {
+	if r.TLS != nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !prefixesContain(trusted, peer.Unmap()) {
+		return false
+	}
+	protos := strings.Split(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","), ",")
+	return strings.EqualFold(strings.TrimSpace(protos[len(protos)-1]), "https")
+}
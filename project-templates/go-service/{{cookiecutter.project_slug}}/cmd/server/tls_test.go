@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestSecureTLSConfig(t *testing.T) {
+	ts := httptest.NewUnstartedServer(okHandler)
+	ts.TLS = secureTLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := ts.Client()
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("negotiated %v, want TLS 1.2+", resp.TLS)
+	}
+
+	old := ts.Client()
+	transport := old.Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.MaxVersion = tls.VersionTLS11
+	old.Transport = transport
+	if resp, err := old.Get(ts.URL); err == nil {
+		resp.Body.Close()
+		t.Error("TLS 1.1 handshake succeeded, want it refused")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		tlsAddr  string
+		method   string
+		wantCode int
+		wantLoc  string
+	}{
+		{name: "get", tlsAddr: ":8443", method: http.MethodGet, wantCode: http.StatusMovedPermanently, wantLoc: "https://example.com:8443/a?b=1"},
+		{name: "post keeps method", tlsAddr: ":8443", method: http.MethodPost, wantCode: http.StatusPermanentRedirect, wantLoc: "https://example.com:8443/a?b=1"},
+		{name: "default port", tlsAddr: ":443", method: http.MethodGet, wantCode: http.StatusMovedPermanently, wantLoc: "https://example.com/a?b=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handleHTTPSRedirect(tt.tlsAddr), httptest.NewRequest(tt.method, "http://example.com:8080/a?b=1", nil))
+			if rec.Code != tt.wantCode || rec.Header().Get("Location") != tt.wantLoc {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Header().Get("Location"), tt.wantCode, tt.wantLoc)
+			}
+		})
+	}
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name     string
+		redirect bool
+		target   string
+		remote   string
+		proto    string
+		tls      bool
+		wantCode int
+		wantLoc  string
+	}{
+		{name: "https", target: "https://example.com/a", tls: true, wantCode: http.StatusOK},
+		{name: "http rejected", target: "http://example.com/a", wantCode: http.StatusBadRequest},
+		{name: "http redirected", redirect: true, target: "http://example.com/a?b=1", wantCode: http.StatusMovedPermanently, wantLoc: "https://example.com/a?b=1"},
+		{name: "https at trusted proxy", target: "http://example.com/a", remote: "10.0.0.1:1234", proto: "https", wantCode: http.StatusOK},
+		{name: "http at trusted proxy", target: "http://example.com/a", remote: "10.0.0.1:1234", proto: "http", wantCode: http.StatusBadRequest},
+		{name: "client-supplied proto", target: "http://example.com/a", remote: "10.0.0.1:1234", proto: "https, http", wantCode: http.StatusBadRequest},
+		{name: "untrusted proxy", target: "http://example.com/a", remote: "203.0.113.7:1234", proto: "https", wantCode: http.StatusBadRequest},
+		{name: "exempt path", target: "http://example.com/livez", wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := withRequireHTTPS(requireHTTPSOptions{
+				TrustedProxies: trusted,
+				Redirect:       tt.redirect,
+				Exempt:         []string{"/livez"},
+			})(okHandler)
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.remote != "" {
+				req.RemoteAddr = tt.remote
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if !tt.tls {
+				req.TLS = nil
+			}
+
+			rec := serve(h, req)
+			if rec.Code != tt.wantCode || rec.Header().Get("Location") != tt.wantLoc {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Header().Get("Location"), tt.wantCode, tt.wantLoc)
+			}
+		})
+	}
+}
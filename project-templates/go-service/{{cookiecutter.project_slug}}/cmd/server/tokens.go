@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// refreshTokenUse is the token_use claim of refresh tokens, so one can't be
+// presented where the other is expected even if the secrets are mixed up.
+const refreshTokenUse = "refresh"
+
+// tokenPair is the response body of the token endpoints.
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // seconds until the access token expires
+}
+
+// jtiDenylist records the IDs of refresh tokens that have been used, so a
+// replayed token is rejected. The in-memory implementation only covers one
+// replica; back it with shared storage (Redis, a database) when running
+// more.
+type jtiDenylist interface {
+	// Revoke adds jti to the list until exp. It reports false if jti was
+	// already there, i.e. the token has been used before.
+	Revoke(ctx context.Context, jti string, exp time.Time) (bool, error)
+}
+
+// denylistSweepInterval is how often memoryDenylist drops expired entries,
+// so Revoke doesn't scan the whole map on every refresh.
+const denylistSweepInterval = time.Minute
+
+// memoryDenylist is a jtiDenylist for a single replica. Entries are swept
+// lazily, at most every denylistSweepInterval, once their token would have
+// expired anyway.
+type memoryDenylist struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func newMemoryDenylist() *memoryDenylist {
+	return &memoryDenylist{entries: map[string]time.Time{}, now: time.Now, lastSweep: time.Now()}
+}
+
+func (d *memoryDenylist) Revoke(_ context.Context, jti string, exp time.Time) (bool, error) {
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.lastSweep) > denylistSweepInterval {
+		for k, e := range d.entries {
+			if now.After(e) {
+				delete(d.entries, k)
+			}
+		}
+		d.lastSweep = now
+	}
+	// An expired entry that hasn't been swept yet doesn't count: the token
+	// it recorded no longer verifies.
+	if e, used := d.entries[jti]; used && !now.After(e) {
+		return false, nil
+	}
+	d.entries[jti] = exp
+	return true, nil
+}
+
+// tokenIssuer mints access and refresh token pairs. Access tokens are
+// signed with the secret withJWTAuth verifies; refresh tokens, when
+// enabled, with a separate secret, so a leaked access token secret can't
+// mint refresh tokens and vice versa.
+type tokenIssuer struct {
+	accessSecret  []byte
+	refreshSecret []byte
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+	issuer        string // iss claim, when set
+	audience      string // aud claim of access tokens, when set
+	denylist      jtiDenylist
+	now           func() time.Time
+}
+
+// newTokenIssuer returns the issuer for cfg, or nil when access tokens
+// can't be issued because withJWTAuth doesn't verify with jwt-secret.
+// Refresh tokens are only issued when --jwt-refresh-secret is set.
+func newTokenIssuer(cfg serverConfig) *tokenIssuer {
+	if cfg.JWTSecret == "" || cfg.JWKSURL != "" {
+		return nil
+	}
+	ti := &tokenIssuer{
+		accessSecret: []byte(cfg.JWTSecret),
+		accessTTL:    cfg.AccessTokenTTL,
+		refreshTTL:   cfg.RefreshTokenTTL,
+		issuer:       cfg.JWTIssuer,
+		audience:     cfg.JWTAudience,
+		denylist:     newMemoryDenylist(),
+		now:          time.Now,
+	}
+	if cfg.JWTRefreshSecret != "" {
+		ti.refreshSecret = []byte(cfg.JWTRefreshSecret)
+	}
+	return ti
+}
+
+// refreshes reports whether ti issues refresh tokens.
+func (ti *tokenIssuer) refreshes() bool {
+	return len(ti.refreshSecret) > 0
+}
+
+// issue returns a new token pair for subject, without a refresh token
+// unless ti.refreshes. scope, when non-empty, is carried in both tokens so
+// it survives rotation.
+func (ti *tokenIssuer) issue(subject, scope string) (tokenPair, error) {
+	now := ti.now()
+	access := jwt.MapClaims{
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(ti.accessTTL).Unix(),
+	}
+	refresh := jwt.MapClaims{
+		"sub":       subject,
+		"jti":       uuid.NewString(),
+		"token_use": refreshTokenUse,
+		"iat":       now.Unix(),
+		"exp":       now.Add(ti.refreshTTL).Unix(),
+	}
+	if ti.issuer != "" {
+		access["iss"], refresh["iss"] = ti.issuer, ti.issuer
+	}
+	if ti.audience != "" {
+		access["aud"] = ti.audience
+	}
+	if scope != "" {
+		access["scope"], refresh["scope"] = scope, scope
+	}
+
+	accessToken, err := signHMAC(ti.accessSecret, access)
+	if err != nil {
+		return tokenPair{}, err
+	}
+	pair := tokenPair{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ti.accessTTL.Seconds()),
+	}
+	if ti.refreshes() {
+		if pair.RefreshToken, err = signHMAC(ti.refreshSecret, refresh); err != nil {
+			return tokenPair{}, err
+		}
+	}
+	return pair, nil
+}
+
+var (
+	// errInvalidRefreshToken wraps every reason rotate rejects a token
+	// other than reuse.
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	// errRefreshTokenReused is returned by rotate for a refresh token that
+	// has already been exchanged, a sign that it may have been stolen.
+	errRefreshTokenReused = errors.New("refresh token already used")
+)
+
+// rotate verifies a refresh token, marks it used and issues a new pair.
+func (ti *tokenIssuer) rotate(ctx context.Context, refreshToken string) (tokenPair, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(ti.now),
+	}
+	if ti.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(ti.issuer))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.NewParser(parserOpts...).ParseWithClaims(refreshToken, claims, hmacKeyfunc(ti.refreshSecret)); err != nil {
+		return tokenPair{}, fmt.Errorf("%w: %w", errInvalidRefreshToken, err)
+	}
+	jti, _ := claims["jti"].(string)
+	subject, _ := claims.GetSubject()
+	if claims["token_use"] != refreshTokenUse || jti == "" || subject == "" {
+		return tokenPair{}, fmt.Errorf("%w: not a refresh token", errInvalidRefreshToken)
+	}
+
+	exp, _ := claims.GetExpirationTime()
+	fresh, err := ti.denylist.Revoke(ctx, jti, exp.Time)
+	if err != nil {
+		return tokenPair{}, fmt.Errorf("recording refresh token use: %w", err)
+	}
+	if !fresh {
+		return tokenPair{}, errRefreshTokenReused
+	}
+
+	scope, _ := claims["scope"].(string)
+	return ti.issue(subject, scope)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *refreshRequest) Validate() error {
+	if r.RefreshToken == "" {
+		return errors.New("refresh_token is required")
+	}
+	return nil
+}
+
+// handleTokenRefresh exchanges a refresh token for a new token pair. Each
+// refresh token works once; presenting it again is logged as a possible
+// replay and rejected.
+func handleTokenRefresh(ti *tokenIssuer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, prob := decodeJSON[refreshRequest](r)
+		if prob != nil {
+			writeProblem(w, r, prob)
+			return
+		}
+
+		pair, err := ti.rotate(r.Context(), req.RefreshToken)
+		switch {
+		case errors.Is(err, errRefreshTokenReused):
+			LoggerFromContext(r.Context()).WarnContext(r.Context(), "refresh token replayed")
+			writeProblem(w, r, problemUnauthorized(err.Error()))
+			return
+		case errors.Is(err, jwt.ErrTokenExpired):
+			writeProblem(w, r, problemUnauthorized("refresh token expired"))
+			return
+		case errors.Is(err, errInvalidRefreshToken):
+			writeProblem(w, r, problemUnauthorized(errInvalidRefreshToken.Error()))
+			return
+		case err != nil:
+			LoggerFromContext(r.Context()).ErrorContext(r.Context(), "refreshing token", "error", err)
+			writeProblem(w, r, problemInternal("internal server error"))
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, pair, http.StatusOK)
+	})
+}
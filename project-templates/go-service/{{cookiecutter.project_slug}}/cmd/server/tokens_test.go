@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testRefreshSecret = "test-refresh-secret"
+
+func newTestTokenIssuer(now func() time.Time) *tokenIssuer {
+	return &tokenIssuer{
+		accessSecret:  []byte(testJWTSecret),
+		refreshSecret: []byte(testRefreshSecret),
+		accessTTL:     15 * time.Minute,
+		refreshTTL:    time.Hour,
+		denylist:      newMemoryDenylist(),
+		now:           now,
+	}
+}
+
+func refreshRequestFor(token string) *http.Request {
+	body, _ := json.Marshal(refreshRequest{RefreshToken: token})
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestHandleTokenRefresh(t *testing.T) {
+	ti := newTestTokenIssuer(time.Now)
+	h := handleTokenRefresh(ti)
+	first, err := ti.issue("u1", "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(h, refreshRequestFor(first.RefreshToken))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	var pair tokenPair
+	if err := json.Unmarshal(rec.Body.Bytes(), &pair); err != nil {
+		t.Fatal(err)
+	}
+	if pair.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != 900 {
+		t.Errorf("token_type, expires_in = %q, %d, want Bearer, 900", pair.TokenType, pair.ExpiresIn)
+	}
+
+	// The new access token passes withJWTAuth and keeps the scope.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	var claims *Claims
+	authed := withJWTAuth(jwtAuthOptions{Keyfunc: hmacKeyfunc([]byte(testJWTSecret))})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = ClaimsFromContext(r.Context())
+	}))
+	if rec := serve(authed, req); rec.Code != http.StatusOK {
+		t.Fatalf("access token rejected: %d %s", rec.Code, rec.Body)
+	}
+	if claims.Subject != "u1" || !claims.HasScope("read") {
+		t.Errorf("claims = %+v, want sub u1 with scope read", claims)
+	}
+
+	// The rotated refresh token works once too.
+	if rec := serve(h, refreshRequestFor(pair.RefreshToken)); rec.Code != http.StatusOK {
+		t.Errorf("rotated token status = %d, want 200", rec.Code)
+	}
+}
+
+func TestHandleTokenRefreshRejects(t *testing.T) {
+	now := time.Now()
+	ti := newTestTokenIssuer(func() time.Time { return now })
+	h := handleTokenRefresh(ti)
+
+	used, err := ti.issue("u1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(h, refreshRequestFor(used.RefreshToken)); rec.Code != http.StatusOK {
+		t.Fatalf("first use status = %d, want 200", rec.Code)
+	}
+
+	expired, err := newTestTokenIssuer(func() time.Time { return now.Add(-2 * time.Hour) }).issue("u1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongSecret, err := signHMAC([]byte("other"), jwt.MapClaims{
+		"sub": "u1", "jti": "x", "token_use": refreshTokenUse, "exp": now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An access token signed with the refresh secret still lacks token_use.
+	notRefresh, err := signHMAC([]byte(testRefreshSecret), jwt.MapClaims{
+		"sub": "u1", "jti": "y", "exp": now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantDetail string
+	}{
+		{name: "reused", token: used.RefreshToken, wantDetail: "refresh token already used"},
+		{name: "expired", token: expired.RefreshToken, wantDetail: "refresh token expired"},
+		{name: "access token", token: used.AccessToken, wantDetail: "invalid refresh token"},
+		{name: "wrong secret", token: wrongSecret, wantDetail: "invalid refresh token"},
+		{name: "not a refresh token", token: notRefresh, wantDetail: "invalid refresh token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, refreshRequestFor(tt.token))
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401", rec.Code)
+			}
+			if got := errorMessage(t, rec.Body.Bytes()); got != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", got, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestMemoryDenylistSweepsExpired(t *testing.T) {
+	now := time.Now()
+	d := newMemoryDenylist()
+	d.now = func() time.Time { return now }
+
+	if fresh, _ := d.Revoke(context.Background(), "a", now.Add(time.Minute)); !fresh {
+		t.Fatal("first Revoke reported reuse")
+	}
+	if fresh, _ := d.Revoke(context.Background(), "a", now.Add(time.Minute)); fresh {
+		t.Fatal("second Revoke did not report reuse")
+	}
+	now = now.Add(2 * time.Minute)
+	d.Revoke(context.Background(), "b", now.Add(time.Minute))
+	if _, ok := d.entries["a"]; ok {
+		t.Error("expired entry was not swept")
+	}
+}
+
+func TestMemoryDenylistSweepsOnInterval(t *testing.T) {
+	now := time.Now()
+	d := newMemoryDenylist()
+	d.now = func() time.Time { return now }
+	d.lastSweep = now
+
+	d.Revoke(context.Background(), "a", now.Add(time.Second))
+	now = now.Add(denylistSweepInterval / 2)
+	d.Revoke(context.Background(), "b", now.Add(time.Hour))
+	if _, ok := d.entries["a"]; !ok {
+		t.Error("entry swept before denylistSweepInterval elapsed")
+	}
+	// Expired but not yet swept: the jti may be recorded again.
+	if fresh, _ := d.Revoke(context.Background(), "a", now.Add(time.Second)); !fresh {
+		t.Error("expired entry reported as reuse")
+	}
+
+	now = now.Add(denylistSweepInterval)
+	d.Revoke(context.Background(), "c", now.Add(time.Hour))
+	if _, ok := d.entries["a"]; ok {
+		t.Error("expired entry was not swept after denylistSweepInterval")
+	}
+	if _, ok := d.entries["b"]; !ok {
+		t.Error("unexpired entry was swept")
+	}
+}
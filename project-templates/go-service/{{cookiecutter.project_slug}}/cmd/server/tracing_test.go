@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider recording ended spans in memory,
+// restoring the global provider and propagator when the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+		tp.Shutdown(context.Background())
+	})
+	return sr
+}
+
+func TestWithTracing(t *testing.T) {
+	sr := recordSpans(t)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var handlerSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.Handle("GET /items/{id}", withTracing()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		if r.PathValue("id") == "boom" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set("traceparent", traceparent)
+	serve(mux, req)
+	serve(mux, httptest.NewRequest(http.MethodGet, "/items/boom", nil))
+	// Outside a ServeMux route, as for a request that matched no pattern.
+	serve(withTracing()(okHandler), httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	ok, failed, unmatched := spans[0], spans[1], spans[2]
+
+	for _, span := range spans[:2] {
+		if span.Name() != "GET /items/{id}" {
+			t.Errorf("span name = %q, want the route pattern", span.Name())
+		}
+		if span.SpanKind() != trace.SpanKindServer {
+			t.Errorf("span kind = %v, want server", span.SpanKind())
+		}
+	}
+	if unmatched.Name() != "GET unknown" {
+		t.Errorf("unmatched span name = %q, want GET unknown", unmatched.Name())
+	}
+
+	if got := ok.Parent().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("parent trace ID = %s, want the one from traceparent", got)
+	}
+	if got := ok.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span ID = %s, want the one from traceparent", got)
+	}
+	if failed.Parent().IsValid() {
+		t.Errorf("span without traceparent has parent %v", failed.Parent())
+	}
+	if handlerSpan.SpanID() != failed.SpanContext().SpanID() {
+		t.Error("handler context doesn't carry the server span")
+	}
+
+	if ok.Status().Code != codes.Unset {
+		t.Errorf("200 span status = %v, want unset", ok.Status().Code)
+	}
+	if failed.Status().Code != codes.Error {
+		t.Errorf("500 span status = %v, want error", failed.Status().Code)
+	}
+}
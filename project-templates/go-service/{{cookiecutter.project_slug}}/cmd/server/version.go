@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v2"
+)
+
+// Build metadata, set at build time with
+//
+//	go build -ldflags "-X main.version=v1.2.3 -X main.commit=abc123 -X main.buildDate=2024-01-01T00:00:00Z"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func currentBuildInfo() buildInfo {
+	return buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+func runVersion(c *cli.Context) error {
+	info := currentBuildInfo()
+	_, err := fmt.Fprintf(c.App.Writer, "version:    %s\ncommit:     %s\nbuild date: %s\ngo:         %s\n",
+		info.Version, info.Commit, info.BuildDate, info.GoVersion)
+	return err
+}
+
+func handleVersion() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, r, currentBuildInfo(), http.StatusOK)
+	})
+}
+
+// newBuildInfoGauge returns the conventional build_info metric: a constant 1
+// whose labels carry the build metadata.
+func newBuildInfoGauge() prometheus.Collector {
+	info := currentBuildInfo()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Build metadata of the running binary; always 1",
+	}, []string{"version", "commit", "build_date", "go_version"})
+	gauge.WithLabelValues(info.Version, info.Commit, info.BuildDate, info.GoVersion).Set(1)
+	return gauge
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"{{cookiecutter.go_mod}}/worker"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// newWorkerFlags returns the worker command's flags. Like the server's, they
+// can be set in the --config file.
+func newWorkerFlags() []cli.Flag {
+	flags := []cli.Flag{
+		newConfigFlag(),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "metrics-addr",
+			Value:   ":9090",
+			Usage:   "Listener for the worker's /metrics endpoint (disabled when empty)",
+			EnvVars: []string{"METRICS_ADDR"},
+		}),
+		&cli.BoolFlag{
+			Name:  "check-connection",
+			Usage: "Check Temporal connection and exit (for health checks)",
+		},
+	}
+	flags = append(flags, temporalClientFlags()...)
+	flags = append(flags, newWorkerOnlyFlags()...)
+	return flags
+}
+
+// temporalClientFlags returns the flags of commands that connect to
+// Temporal: the frontend address, namespace, TLS and credentials, and the
+// log settings the client logs with.
+func temporalClientFlags() []cli.Flag {
+	flags := []cli.Flag{
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "temporal-address",
+			Aliases: []string{"temporal-addr"},
+			Value:   "localhost:7233",
+			EnvVars: []string{"TEMPORAL_ADDRESS"},
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "temporal-namespace",
+			Aliases: []string{"namespace"},
+			Value:   "default",
+			EnvVars: []string{"TEMPORAL_NAMESPACE"},
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "log-level",
+			Value:   "info",
+			EnvVars: []string{"LOG_LEVEL"},
+		}),
+	}
+	flags = append(flags, logFlags()...)
+	for _, f := range temporalConnectionFlags() {
+		flags = append(flags, altsrc.NewStringFlag(f))
+	}
+	return flags
+}
+
+// newWorkerOnlyFlags returns the flags only the worker uses, which the all
+// command adds to the server's.
+func newWorkerOnlyFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "task-queue",
+			Value:   "{{cookiecutter.project_slug}}",
+			EnvVars: []string{"TEMPORAL_TASK_QUEUE"},
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "max-concurrent-activities",
+			Value:   worker.DefaultTuning().MaxConcurrentActivityExecutionSize,
+			Usage:   "Maximum activities this worker executes at once",
+			EnvVars: []string{"TEMPORAL_MAX_CONCURRENT_ACTIVITIES"},
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "max-concurrent-workflow-tasks",
+			Value:   worker.DefaultTuning().MaxConcurrentWorkflowTaskExecutionSize,
+			Usage:   "Maximum workflow tasks this worker executes at once (at least 2)",
+			EnvVars: []string{"TEMPORAL_MAX_CONCURRENT_WORKFLOW_TASKS"},
+		}),
+		altsrc.NewFloat64Flag(&cli.Float64Flag{
+			Name:    "activities-per-second",
+			Value:   worker.DefaultTuning().WorkerActivitiesPerSecond,
+			Usage:   "Rate limit on activities started by this worker",
+			EnvVars: []string{"TEMPORAL_ACTIVITIES_PER_SECOND"},
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "worker-stop-timeout",
+			Value:   30 * time.Second,
+			Usage:   "Time the all command waits for the worker to stop, after the HTTP server has drained (0 waits indefinitely)",
+			EnvVars: []string{"WORKER_STOP_TIMEOUT"},
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    "connect-max-attempts",
+			Value:   worker.DefaultRetryPolicy().MaxAttempts,
+			Usage:   "Attempts to connect to Temporal before the worker gives up",
+			EnvVars: []string{"TEMPORAL_CONNECT_MAX_ATTEMPTS"},
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "connect-base-interval",
+			Value:   worker.DefaultRetryPolicy().BaseInterval,
+			Usage:   "Wait after the first failed connection attempt; doubles per attempt, with jitter",
+			EnvVars: []string{"TEMPORAL_CONNECT_BASE_INTERVAL"},
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "connect-max-interval",
+			Value:   worker.DefaultRetryPolicy().MaxInterval,
+			Usage:   "Upper bound on the wait between connection attempts",
+			EnvVars: []string{"TEMPORAL_CONNECT_MAX_INTERVAL"},
+		}),
+	}
+}
+
+// workerConfig is the effective worker configuration.
+type workerConfig struct {
+	Log         logOptions
+	MetricsAddr string
+
+	TemporalAddress    string
+	TemporalNamespace  string
+	TemporalConnection worker.ConnectionConfig
+
+	TaskQueue   string
+	Tuning      worker.Tuning
+	Retry       worker.RetryPolicy
+	StopTimeout time.Duration
+}
+
+func newWorkerConfig(c *cli.Context) workerConfig {
+	return workerConfig{
+		Log:         newLogOptions(c),
+		MetricsAddr: c.String("metrics-addr"),
+
+		TemporalAddress:    c.String("temporal-address"),
+		TemporalNamespace:  c.String("temporal-namespace"),
+		TemporalConnection: newTemporalConnectionConfig(c),
+
+		TaskQueue: c.String("task-queue"),
+		Tuning: worker.Tuning{
+			MaxConcurrentActivityExecutionSize:     c.Int("max-concurrent-activities"),
+			MaxConcurrentWorkflowTaskExecutionSize: c.Int("max-concurrent-workflow-tasks"),
+			WorkerActivitiesPerSecond:              c.Float64("activities-per-second"),
+		},
+		Retry: worker.RetryPolicy{
+			MaxAttempts:  c.Int("connect-max-attempts"),
+			BaseInterval: c.Duration("connect-base-interval"),
+			MaxInterval:  c.Duration("connect-max-interval"),
+		},
+		StopTimeout: c.Duration("worker-stop-timeout"),
+	}
+}
+
+// Validate reports every problem with cfg at once.
+func (cfg workerConfig) Validate() error {
+	errs := []error{cfg.validateConnection()}
+	if cfg.TaskQueue == "" {
+		errs = append(errs, errors.New("task-queue is required"))
+	}
+	if err := cfg.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Tuning.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Retry.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.StopTimeout < 0 {
+		errs = append(errs, fmt.Errorf("worker-stop-timeout must not be negative, got %v", cfg.StopTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+// validateConnection checks the settings every command dialing Temporal
+// needs.
+func (cfg workerConfig) validateConnection() error {
+	var errs []error
+	if cfg.TemporalAddress == "" {
+		errs = append(errs, errors.New("temporal-address is required"))
+	}
+	if (cfg.TemporalConnection.TLSCertFile == "") != (cfg.TemporalConnection.TLSKeyFile == "") {
+		errs = append(errs, errors.New("temporal-tls-cert and temporal-tls-key must be set together"))
+	}
+	return errors.Join(errs...)
+}
+
+func runWorker(c *cli.Context) error {
+	cfg := newWorkerConfig(c)
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	logger, logLevel, closeLog, err := setupLogger(cfg.Log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	// Health check mode
+	if c.Bool("check-connection") {
+		clients, err := worker.NewClientProvider(logger, cfg.TemporalAddress, cfg.TemporalNamespace, cfg.TemporalConnection, nil)
+		if err != nil {
+			return fmt.Errorf("configuring temporal client: %w", err)
+		}
+		defer clients.Close()
+		if err := worker.CheckConnection(c.Context, clients); err != nil {
+			return err
+		}
+		logger.Info("health check successful")
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, shutdownSignals...)
+	defer stop()
+
+	reloadLogLevelOnHangup(ctx, logger, logLevel, func() (string, error) {
+		return configFileLogLevel(c)
+	})
+	cycleLogLevelOnSignal(ctx, logger, logLevel)
+
+	promRegistry := prometheus.NewRegistry()
+	promRegistry.MustRegister(newBuildInfoGauge())
+	temporalMetrics, closeTemporalMetrics := worker.NewMetricsHandler(logger, promRegistry)
+	defer closeTemporalMetrics.Close()
+
+	clients, err := worker.NewClientProvider(logger, cfg.TemporalAddress, cfg.TemporalNamespace, cfg.TemporalConnection, temporalMetrics)
+	if err != nil {
+		return fmt.Errorf("configuring temporal client: %w", err)
+	}
+	defer clients.Close()
+	clients.SetConnectionMetrics(worker.NewConnectionMetrics(promRegistry))
+
+	// The worker has no other HTTP surface, so it serves /metrics on its own
+	// listener.
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}))
+		metricsServer := &http.Server{
+			Addr:              cfg.MetricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			logger.Info("metrics server started", "addr", cfg.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("metrics server failed", "error", err)
+			}
+		}()
+		defer metricsServer.Close()
+	}
+
+	return worker.RunWorker(ctx, logger, clients, cfg.TaskQueue, worker.DefaultRegistrations(), cfg.Tuning, cfg.Retry)
+}
+
+// runAll runs the HTTP server and the worker in one process under
+// supervise, which on shutdown drains the server before stopping the
+// worker. They share a Temporal client, which also backs /readyz, and a
+// metrics registry, so worker metrics appear on the server's /metrics.
+func runAll(c *cli.Context) error {
+	serverCfg := newServerConfig(c)
+	workerCfg := newWorkerConfig(c)
+	if err := errors.Join(serverCfg.Validate(), workerCfg.Validate()); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	logger, logLevel, closeLog, err := setupLogger(serverCfg.Log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+	logger.Info("starting", "version", version, "config", serverCfg)
+
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+
+	reloadLogLevelOnHangup(ctx, logger, logLevel, func() (string, error) {
+		return configFileLogLevel(c)
+	})
+	cycleLogLevelOnSignal(ctx, logger, logLevel)
+
+	promRegistry := prometheus.NewRegistry()
+	promRegistry.MustRegister(newBuildInfoGauge())
+	temporalMetrics, closeTemporalMetrics := worker.NewMetricsHandler(logger, promRegistry)
+	defer closeTemporalMetrics.Close()
+
+	clients, err := worker.NewClientProvider(logger, workerCfg.TemporalAddress, workerCfg.TemporalNamespace, workerCfg.TemporalConnection, temporalMetrics)
+	if err != nil {
+		return fmt.Errorf("configuring temporal client: %w", err)
+	}
+	defer clients.Close()
+	clients.SetConnectionMetrics(worker.NewConnectionMetrics(promRegistry))
+
+	return supervise(ctx, logger, shutdownSignals,
+		phase{
+			Name: "http",
+			Run: func(ctx context.Context) error {
+				return serveHTTP(ctx, serverCfg, logger, logLevel, promRegistry, clients)
+			},
+			// serveHTTP bounds its drain by shutdown-delay and
+			// shutdown-timeout.
+		},
+		phase{
+			Name: "worker",
+			Run: func(ctx context.Context) error {
+				return worker.RunWorker(ctx, logger, clients, workerCfg.TaskQueue, worker.DefaultRegistrations(), workerCfg.Tuning, workerCfg.Retry)
+			},
+			StopTimeout: workerCfg.StopTimeout,
+		},
+	)
+}
+
+// shutdownSignals stop the long-running commands gracefully.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
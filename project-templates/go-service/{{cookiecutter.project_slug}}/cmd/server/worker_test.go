@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"{{cookiecutter.go_mod}}/worker"
+
+	"github.com/urfave/cli/v2"
+)
+
+// loadWorkerConfig runs the worker command's flag handling on args and
+// returns the config runWorker would pass to worker.RunWorker.
+func loadWorkerConfig(t *testing.T, args ...string) workerConfig {
+	t.Helper()
+	var cfg workerConfig
+	flags := newWorkerFlags()
+	app := &cli.App{
+		Commands: []*cli.Command{{
+			Name:   "worker",
+			Flags:  flags,
+			Before: loadConfigFile(flags),
+			Action: func(c *cli.Context) error {
+				cfg = newWorkerConfig(c)
+				return nil
+			},
+		}},
+	}
+	if err := app.Run(append([]string{"app", "worker"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestWorkerConfigFromFlags(t *testing.T) {
+	t.Setenv("TEMPORAL_TASK_QUEUE", "from-env")
+	cfg := loadWorkerConfig(t,
+		"--temporal-addr", "temporal:7233",
+		"--namespace", "prod",
+		"--max-concurrent-activities", "10",
+		"--temporal-api-key", "key",
+	)
+
+	want := workerConfig{
+		Log: logOptions{
+			Level:      "info",
+			Format:     "json",
+			Output:     "stderr",
+			MaxSizeMB:  100,
+			Redact:     true,
+			RedactKeys: defaultRedactKeys,
+		},
+		MetricsAddr:       ":9090",
+		TemporalAddress:   "temporal:7233",
+		TemporalNamespace: "prod",
+		TemporalConnection: worker.ConnectionConfig{
+			APIKey: "key",
+		},
+		TaskQueue: "from-env",
+		Tuning: worker.Tuning{
+			MaxConcurrentActivityExecutionSize:     10,
+			MaxConcurrentWorkflowTaskExecutionSize: worker.DefaultTuning().MaxConcurrentWorkflowTaskExecutionSize,
+			WorkerActivitiesPerSecond:              worker.DefaultTuning().WorkerActivitiesPerSecond,
+		},
+		Retry:       worker.DefaultRetryPolicy(),
+		StopTimeout: 30 * time.Second,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestWorkerConfigValidate(t *testing.T) {
+	if err := loadWorkerConfig(t).Validate(); err != nil {
+		t.Errorf("defaults: Validate() = %v, want nil", err)
+	}
+
+	err := workerConfig{Tuning: worker.DefaultTuning(), Retry: worker.DefaultRetryPolicy()}.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want error")
+	}
+	for _, want := range []string{"temporal-address is required", "task-queue is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %q, missing %q", err, want)
+		}
+	}
+}
+
+func TestWorkflowStartFlags(t *testing.T) {
+	var got []string
+	var wait bool
+	flags := newWorkflowStartFlags()
+	app := &cli.App{
+		Commands: []*cli.Command{{
+			Name:   "start",
+			Flags:  flags,
+			Before: loadConfigFile(flags),
+			Action: func(c *cli.Context) error {
+				got = append([]string{c.String("task-queue"), c.String("temporal-address")}, c.Args().Slice()...)
+				wait = c.Bool("wait")
+				return nil
+			},
+		}},
+	}
+	err := app.Run([]string{"app", "start", "--wait", "--temporal-addr", "temporal:7233", "GreetingWorkflow", `"Temporal"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"{{cookiecutter.project_slug}}", "temporal:7233", "GreetingWorkflow", `"Temporal"`}
+	if !reflect.DeepEqual(got, want) || !wait {
+		t.Errorf("args = %q, wait = %v; want %q, true", got, wait, want)
+	}
+}
+
+func TestRunWorkflowStartRequiresType(t *testing.T) {
+	flags := newWorkflowStartFlags()
+	app := &cli.App{
+		Commands: []*cli.Command{{Name: "start", Flags: flags, Action: runWorkflowStart}},
+	}
+	err := app.Run([]string{"app", "start"})
+	if err == nil || !strings.Contains(err.Error(), "WORKFLOW_TYPE") {
+		t.Errorf("err = %v, want usage error", err)
+	}
+}
+
+// supervisedTask blocks until its context is done, like serveHTTP and
+// RunWorker, reporting when it has started and stopped.
+func supervisedTask(started, stopped chan<- string, name string) phase {
+	return phase{Name: name, Run: func(ctx context.Context) error {
+		started <- name
+		<-ctx.Done()
+		stopped <- name
+		return nil
+	}}
+}
+
+func TestSuperviseStopsAllOnSignal(t *testing.T) {
+	started := make(chan string, 2)
+	stopped := make(chan string, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- supervise(context.Background(), discardLogger, []os.Signal{syscall.SIGUSR1},
+			supervisedTask(started, stopped, "server"),
+			supervisedTask(started, stopped, "worker"))
+	}()
+	// Tasks start after supervise subscribes to the signal.
+	<-started
+	<-started
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatalf("sending SIGUSR1: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("supervise = %v, want nil after a signal", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervise did not return after the signal")
+	}
+	if len(stopped) != 2 {
+		t.Errorf("%d tasks stopped, want 2", len(stopped))
+	}
+}
+
+func TestSuperviseFailureStopsOthers(t *testing.T) {
+	started := make(chan string, 1)
+	stopped := make(chan string, 1)
+	failure := errors.New("listen tcp :8080: address already in use")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- supervise(context.Background(), discardLogger, []os.Signal{syscall.SIGUSR1},
+			supervisedTask(started, stopped, "worker"),
+			phase{Name: "server", Run: func(context.Context) error { return failure }})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, failure) {
+			t.Errorf("supervise = %v, want the failing task's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervise did not return after a task failed")
+	}
+	if got := <-stopped; got != "worker" {
+		t.Errorf("stopped %q, want worker", got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/signal"
+
+	"{{cookiecutter.go_mod}}/worker"
+
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// newWorkflowStartFlags returns the flags of the workflow start command.
+// The connection flags match the worker's, so both can share a --config
+// file.
+func newWorkflowStartFlags() []cli.Flag {
+	flags := []cli.Flag{
+		newConfigFlag(),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "task-queue",
+			Value:   "{{cookiecutter.project_slug}}",
+			Usage:   "Task queue the workflow is started on",
+			EnvVars: []string{"TEMPORAL_TASK_QUEUE"},
+		}),
+		&cli.StringFlag{
+			Name:  "workflow-id",
+			Usage: "Workflow ID (defaults to the workflow type followed by a random UUID)",
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "Wait for the workflow to complete and print its result",
+		},
+	}
+	return append(flags, temporalClientFlags()...)
+}
+
+// runWorkflowStart starts the workflow type named by the first argument,
+// passing each following argument, parsed as JSON, as a workflow argument:
+//
+//	workflow start --wait GreetingWorkflow '"Temporal"'
+//
+// It prints the workflow and run IDs, and with --wait the result, as JSON.
+func runWorkflowStart(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return errors.New("usage: workflow start [flags] WORKFLOW_TYPE [JSON_ARG...]")
+	}
+	cfg := newWorkerConfig(c)
+	if err := errors.Join(cfg.Log.Validate(), cfg.validateConnection()); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	logger, _, closeLog, err := setupLogger(cfg.Log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	ctx, stop := signal.NotifyContext(c.Context, shutdownSignals...)
+	defer stop()
+
+	clients, err := worker.NewClientProvider(logger, cfg.TemporalAddress, cfg.TemporalNamespace, cfg.TemporalConnection, nil)
+	if err != nil {
+		return fmt.Errorf("configuring temporal client: %w", err)
+	}
+	defer clients.Close()
+
+	res, err := worker.StartWorkflow(ctx, clients, worker.StartRequest{
+		WorkflowType: c.Args().First(),
+		WorkflowID:   c.String("workflow-id"),
+		TaskQueue:    c.String("task-queue"),
+		Args:         c.Args().Tail(),
+		Wait:         c.Bool("wait"),
+	})
+	if res.WorkflowID != "" {
+		// Print the IDs even if waiting failed, so the run can be found.
+		enc := json.NewEncoder(c.App.Writer)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	return err
+}
@@ -3,8 +3,24 @@ module {{cookiecutter.go_mod}}
 go 1.24
 
 require (
+	github.com/alicebob/miniredis/v2 v2.35.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/uuid v1.6.0
 	github.com/prometheus/client_golang v1.20.5
+	github.com/redis/go-redis/v9 v9.7.3
+	github.com/uber-go/tally/v4 v4.1.16
+	github.com/ugorji/go/codec v1.2.12
 	github.com/urfave/cli/v2 v2.27.5
+	go.opentelemetry.io/otel v1.31.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.31.0
+	go.opentelemetry.io/otel/sdk v1.31.0
+	go.opentelemetry.io/otel/trace v1.31.0
+	go.temporal.io/api v1.43.0
 	go.temporal.io/sdk v1.31.0
+	go.temporal.io/sdk/contrib/tally v0.2.0
+	golang.org/x/crypto v0.28.0
+	golang.org/x/oauth2 v0.24.0
+	golang.org/x/sync v0.8.0
+	golang.org/x/time v0.8.0
+	google.golang.org/grpc v1.66.0
 )
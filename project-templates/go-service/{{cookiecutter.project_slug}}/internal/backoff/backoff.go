@@ -0,0 +1,38 @@
+// Package backoff computes waits between retries. The worker uses it for
+// its initial Temporal connection and the server for outbound HTTP calls,
+// so both back off the same way.
+package backoff
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Wait returns the wait after the given failed attempt (1-based): base
+// doubled per earlier attempt, capped at max. It uses "equal jitter", half
+// that interval plus a random amount up to the other half, so instances
+// don't retry in lockstep and waits never shrink from one attempt to the
+// next.
+func Wait(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	d = min(d, max)
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// Sleep waits for d or until ctx is done, returning ctx's error in the
+// latter case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,31 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	const base, max = time.Second, 10 * time.Second
+	for attempt := 1; attempt <= 50; attempt++ {
+		d := Wait(attempt, base, max)
+		// The interval before jitter: 1s, 2s, 4s, 8s, then 10s.
+		interval := min(base<<min(attempt-1, 4), max)
+		if d < interval/2 || d > interval {
+			t.Fatalf("Wait(%d) = %v, want between %v and %v", attempt, d, interval/2, interval)
+		}
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep = %v, want nil", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep with cancelled ctx = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	sdklog "go.temporal.io/sdk/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// DialTimeout bounds a dial, including the initial server capabilities
+// call, so an unresponsive frontend can't hang a caller indefinitely.
+const DialTimeout = 5 * time.Second
+
+// ErrClientProviderClosed is returned by ClientProvider calls made after
+// Close.
+var ErrClientProviderClosed = errors.New("temporal client provider closed")
+
+// ClientProvider dials Temporal on first use and shares the connection
+// between the worker and health checks, so probes don't open a new client
+// each time. It is safe for concurrent use.
+type ClientProvider struct {
+	options client.Options
+	dial    func(context.Context, client.Options) (client.Client, error)
+	flight  singleflight.Group
+	metrics *ConnectionMetrics
+
+	mu     sync.Mutex
+	client client.Client
+	closed bool
+}
+
+// NewClientProvider configures, but doesn't dial, the shared client. A nil
+// metrics handler disables SDK metrics.
+func NewClientProvider(l *slog.Logger, temporalAddr, namespace string, conn ConnectionConfig, metrics client.MetricsHandler) (*ClientProvider, error) {
+	options := client.Options{
+		Logger:            sdklog.NewStructuredLogger(l),
+		HostPort:          temporalAddr,
+		Namespace:         namespace,
+		MetricsHandler:    metrics,
+		ConnectionOptions: client.ConnectionOptions{GetSystemInfoTimeout: DialTimeout},
+	}
+	if err := conn.apply(&options); err != nil {
+		return nil, err
+	}
+	return &ClientProvider{options: options, dial: client.DialContext}, nil
+}
+
+// ConnectionConfig holds the credentials for clusters that aren't a local
+// insecure dev server. Set TLSCertFile and TLSKeyFile for mTLS, APIKey for
+// Temporal Cloud API-key auth, or both. TLSCAFile is only needed for a
+// private CA; the system roots are used otherwise.
+type ConnectionConfig struct {
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSCAFile     string
+	TLSServerName string
+	APIKey        string
+}
+
+// apply sets TLS and credentials on opts. TLS is enabled whenever any TLS
+// setting or an API key is present, since Temporal Cloud requires it.
+func (conn ConnectionConfig) apply(opts *client.Options) error {
+	if (conn.TLSCertFile == "") != (conn.TLSKeyFile == "") {
+		return errors.New("temporal TLS cert and key must be set together")
+	}
+	if conn.TLSCertFile == "" && conn.TLSCAFile == "" && conn.TLSServerName == "" && conn.APIKey == "" {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: conn.TLSServerName,
+	}
+	if conn.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conn.TLSCertFile, conn.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("loading temporal TLS key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if conn.TLSCAFile != "" {
+		pem, err := os.ReadFile(conn.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("reading temporal TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in temporal TLS CA %s", conn.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	opts.ConnectionOptions.TLS = tlsConfig
+
+	if conn.APIKey != "" {
+		opts.Credentials = client.NewAPIKeyStaticCredentials(conn.APIKey)
+	}
+	return nil
+}
+
+// SetConnectionMetrics makes the worker's connection attempts and
+// CheckConnection report to m. Call it before the provider is used.
+func (p *ClientProvider) SetConnectionMetrics(m *ConnectionMetrics) {
+	p.metrics = m
+}
+
+// Client returns the shared client, dialing if no dial has succeeded yet.
+// Failed dials aren't cached, so the next call retries.
+func (p *ClientProvider) Client() (client.Client, error) {
+	return p.ClientContext(context.Background())
+}
+
+// ClientContext is Client, but stops waiting for a dial when ctx is done.
+// Concurrent callers share one dial, bounded by DialTimeout rather than any
+// one caller's ctx, so a caller giving up doesn't fail the others.
+func (p *ClientProvider) ClientContext(ctx context.Context) (client.Client, error) {
+	p.mu.Lock()
+	c, closed := p.client, p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrClientProviderClosed
+	}
+	if c != nil {
+		return c, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ch := p.flight.DoChan("dial", func() (interface{}, error) {
+		dialCtx, cancel := context.WithTimeout(context.Background(), DialTimeout)
+		defer cancel()
+		c, err := p.dial(dialCtx, p.options)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.closed {
+			// Close ran while we were dialing; nothing else will close c.
+			c.Close()
+			return nil, ErrClientProviderClosed
+		}
+		p.client = c
+		return c, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(client.Client), nil
+	}
+}
+
+// ExecuteWorkflow starts a workflow with the shared client. ctx bounds both
+// the dial, if one is needed, and the start call, so passing an HTTP
+// request's context aborts the call when the client disconnects or the
+// request times out. The workflow itself keeps running once started.
+func (p *ClientProvider) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	c, err := p.ClientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.ExecuteWorkflow(ctx, options, workflow, args...)
+}
+
+// SignalWithStartWorkflow signals the workflow with workflowID, starting it
+// first if it isn't running. ctx is used as in ExecuteWorkflow.
+func (p *ClientProvider) SignalWithStartWorkflow(ctx context.Context, workflowID, signalName string, signalArg interface{}, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	c, err := p.ClientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, options, workflow, args...)
+}
+
+// connectAttempt is ClientContext for the worker's connection retry loop:
+// each call counts as an attempt in the connection metrics.
+func (p *ClientProvider) connectAttempt(ctx context.Context) (client.Client, error) {
+	c, err := p.ClientContext(ctx)
+	p.metrics.observe(err)
+	return c, err
+}
+
+// namespace returns the namespace the client is bound to, applying the
+// SDK's default.
+func (p *ClientProvider) namespace() string {
+	if p.options.Namespace == "" {
+		return client.DefaultNamespace
+	}
+	return p.options.Namespace
+}
+
+// Close closes the shared client, if one was dialed. A dial still in
+// flight closes its client when it completes, and later calls return
+// ErrClientProviderClosed.
+func (p *ClientProvider) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+}
@@ -0,0 +1,239 @@
+package worker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// fakeClient is a client.Client that records Close calls. Other methods
+// panic via the nil embedded interface.
+type fakeClient struct {
+	client.Client
+	closed atomic.Bool
+}
+
+func (f *fakeClient) Close() { f.closed.Store(true) }
+
+func TestClientProviderDialsOnce(t *testing.T) {
+	var dials atomic.Int32
+	fake := &fakeClient{}
+	p := &ClientProvider{dial: func(context.Context, client.Options) (client.Client, error) {
+		dials.Add(1)
+		return fake, nil
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := p.Client()
+			if err != nil || c != fake {
+				t.Errorf("Client() = %v, %v, want shared client", c, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := dials.Load(); n != 1 {
+		t.Errorf("dialed %d times, want 1", n)
+	}
+
+	p.Close()
+	if !fake.closed.Load() {
+		t.Error("Close did not close the shared client")
+	}
+	if _, err := p.Client(); !errors.Is(err, ErrClientProviderClosed) {
+		t.Errorf("Client() after Close = %v, want ErrClientProviderClosed", err)
+	}
+}
+
+func TestClientProviderClosesClientDialedDuringClose(t *testing.T) {
+	fake := &fakeClient{}
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	p := &ClientProvider{dial: func(context.Context, client.Options) (client.Client, error) {
+		close(dialing)
+		<-release
+		return fake, nil
+	}}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := p.Client()
+		errc <- err
+	}()
+	<-dialing
+	p.Close()
+	close(release)
+
+	if err := <-errc; !errors.Is(err, ErrClientProviderClosed) {
+		t.Errorf("Client() = %v, want ErrClientProviderClosed", err)
+	}
+	if !fake.closed.Load() {
+		t.Error("client dialed during Close was not closed")
+	}
+}
+
+func TestClientProviderRetriesFailedDial(t *testing.T) {
+	fake := &fakeClient{}
+	fail := true
+	p := &ClientProvider{dial: func(context.Context, client.Options) (client.Client, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return fake, nil
+	}}
+
+	if _, err := p.Client(); err == nil {
+		t.Fatal("Client() = nil error, want dial failure")
+	}
+	fail = false
+	if c, err := p.Client(); err != nil || c != fake {
+		t.Errorf("Client() after failure = %v, %v, want shared client", c, err)
+	}
+}
+
+// writeTestKeyPair writes a self-signed certificate and its key to dir and
+// returns their paths. The certificate doubles as a CA bundle.
+func writeTestKeyPair(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "worker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// blockingStarter is a client.Client whose workflow calls block until
+// their context is done, like a call to an unresponsive frontend.
+type blockingStarter struct {
+	client.Client
+}
+
+func (blockingStarter) ExecuteWorkflow(ctx context.Context, _ client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingStarter) SignalWithStartWorkflow(ctx context.Context, _, _ string, _ interface{}, _ client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClientProviderWorkflowCallsHonorContext(t *testing.T) {
+	p := &ClientProvider{dial: func(context.Context, client.Options) (client.Client, error) {
+		return blockingStarter{}, nil
+	}}
+	calls := map[string]func(context.Context) error{
+		"ExecuteWorkflow": func(ctx context.Context) error {
+			_, err := p.ExecuteWorkflow(ctx, client.StartWorkflowOptions{}, "W")
+			return err
+		},
+		"SignalWithStartWorkflow": func(ctx context.Context) error {
+			_, err := p.SignalWithStartWorkflow(ctx, "id", "sig", nil, client.StartWorkflowOptions{}, "W")
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- call(ctx) }()
+			cancel()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("err = %v, want context.Canceled", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("call not aborted by cancelled context")
+			}
+		})
+	}
+}
+
+func TestConnectionConfigApply(t *testing.T) {
+	certFile, keyFile := writeTestKeyPair(t, t.TempDir())
+
+	t.Run("insecure by default", func(t *testing.T) {
+		var opts client.Options
+		if err := (ConnectionConfig{}).apply(&opts); err != nil {
+			t.Fatal(err)
+		}
+		if opts.ConnectionOptions.TLS != nil || opts.Credentials != nil {
+			t.Errorf("options = %+v, want no TLS or credentials", opts)
+		}
+	})
+
+	t.Run("mtls", func(t *testing.T) {
+		var opts client.Options
+		conn := ConnectionConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSCAFile: certFile, TLSServerName: "temporal.example"}
+		if err := conn.apply(&opts); err != nil {
+			t.Fatal(err)
+		}
+		tlsConfig := opts.ConnectionOptions.TLS
+		if tlsConfig == nil || len(tlsConfig.Certificates) != 1 || tlsConfig.RootCAs == nil || tlsConfig.ServerName != "temporal.example" {
+			t.Errorf("TLS config = %+v, want client cert, CA pool and server name", tlsConfig)
+		}
+		if opts.Credentials != nil {
+			t.Error("credentials set without an API key")
+		}
+	})
+
+	t.Run("api key", func(t *testing.T) {
+		var opts client.Options
+		if err := (ConnectionConfig{APIKey: "secret"}).apply(&opts); err != nil {
+			t.Fatal(err)
+		}
+		if opts.Credentials == nil || opts.ConnectionOptions.TLS == nil {
+			t.Errorf("options = %+v, want API key credentials over TLS", opts)
+		}
+	})
+
+	t.Run("cert without key", func(t *testing.T) {
+		var opts client.Options
+		if err := (ConnectionConfig{TLSCertFile: certFile}).apply(&opts); err == nil {
+			t.Error("apply() = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// GreetingWorkflow is an example workflow that greets name via the Greet
+// activity. Replace it with the service's own workflows.
+func GreetingWorkflow(ctx workflow.Context, name string) (string, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	})
+
+	var a *Activities
+	var greeting string
+	if err := workflow.ExecuteActivity(ctx, a.Greet, name).Get(ctx, &greeting); err != nil {
+		return "", err
+	}
+	return greeting, nil
+}
+
+// Activities holds the dependencies (database, HTTP clients...) shared by
+// the activity methods. Register a pointer to it so every exported method
+// becomes an activity.
+type Activities struct{}
+
+// Greet is an example activity.
+func (a *Activities) Greet(ctx context.Context, name string) (string, error) {
+	return fmt.Sprintf("Hello, %s!", name), nil
+}
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestDefaultRegistrations(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	DefaultRegistrations().Register(env)
+
+	env.ExecuteWorkflow(GreetingWorkflow, "Temporal")
+
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow error: %v", err)
+	}
+	var greeting string
+	if err := env.GetWorkflowResult(&greeting); err != nil {
+		t.Fatal(err)
+	}
+	if greeting != "Hello, Temporal!" {
+		t.Errorf("greeting = %q, want %q", greeting, "Hello, Temporal!")
+	}
+}
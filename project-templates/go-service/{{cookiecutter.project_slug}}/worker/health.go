@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	enums "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConnectionErrorKind classifies why CheckConnection failed.
+type ConnectionErrorKind int
+
+const (
+	// ConnectionUnavailable means the frontend was unreachable or unhealthy.
+	ConnectionUnavailable ConnectionErrorKind = iota
+	// ConnectionTimeout means ctx ended or the dial timed out first.
+	ConnectionTimeout
+	// ConnectionUnauthorized means the frontend rejected the TLS
+	// handshake or the credentials.
+	ConnectionUnauthorized
+	// ConnectionNamespaceNotFound means the frontend is up but the
+	// configured namespace isn't registered.
+	ConnectionNamespaceNotFound
+)
+
+func (k ConnectionErrorKind) String() string {
+	switch k {
+	case ConnectionTimeout:
+		return "timeout"
+	case ConnectionUnauthorized:
+		return "unauthorized"
+	case ConnectionNamespaceNotFound:
+		return "namespace not found"
+	default:
+		return "unavailable"
+	}
+}
+
+// ConnectionError is the error CheckConnection returns. Use errors.As to
+// tell a timeout, which may clear up on its own, from an auth failure,
+// which needs a configuration change.
+type ConnectionError struct {
+	Kind ConnectionErrorKind
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("temporal health check failed (%s): %v", e.Kind, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// CheckConnection verifies the shared Temporal client can reach the
+// frontend and that the configured namespace is registered, which catches
+// a wrong --namespace that a reachable frontend alone wouldn't. Used for
+// health checks, so it gives up as soon as ctx is done, including while
+// the first dial is still in progress.
+func CheckConnection(ctx context.Context, clients *ClientProvider) (err error) {
+	defer func() { clients.metrics.observe(err) }()
+
+	c, err := clients.ClientContext(ctx)
+	if err != nil {
+		return newConnectionError(err)
+	}
+	if _, err := c.CheckHealth(ctx, &client.CheckHealthRequest{}); err != nil {
+		return newConnectionError(err)
+	}
+
+	namespace := clients.namespace()
+	resp, err := c.WorkflowService().DescribeNamespace(ctx, &workflowservice.DescribeNamespaceRequest{Namespace: namespace})
+	if err != nil {
+		if grpcStatus(err).Code() == codes.NotFound {
+			return &ConnectionError{Kind: ConnectionNamespaceNotFound, Err: fmt.Errorf("namespace %q not found: %w", namespace, err)}
+		}
+		return newConnectionError(err)
+	}
+	if state := resp.GetNamespaceInfo().GetState(); state != enums.NAMESPACE_STATE_REGISTERED {
+		return &ConnectionError{Kind: ConnectionNamespaceNotFound, Err: fmt.Errorf("namespace %q is %s", namespace, state)}
+	}
+
+	return nil
+}
+
+func newConnectionError(err error) *ConnectionError {
+	return &ConnectionError{Kind: classifyConnectionError(err), Err: err}
+}
+
+func classifyConnectionError(err error) ConnectionErrorKind {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ConnectionTimeout
+	}
+	st := grpcStatus(err)
+	switch st.Code() {
+	case codes.DeadlineExceeded, codes.Canceled:
+		return ConnectionTimeout
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return ConnectionUnauthorized
+	case codes.Unavailable:
+		// gRPC reports TLS failures as Unavailable.
+		if strings.Contains(st.Message(), "authentication handshake failed") {
+			return ConnectionUnauthorized
+		}
+	}
+	return ConnectionUnavailable
+}
+
+// grpcStatus returns the gRPC status behind err. SDK service errors carry
+// it in Status; raw gRPC errors in GRPCStatus, which status.FromError
+// understands.
+func grpcStatus(err error) *status.Status {
+	var svcErr interface{ Status() *status.Status }
+	if errors.As(err, &svcErr) {
+		return svcErr.Status()
+	}
+	st, _ := status.FromError(err)
+	return st
+}
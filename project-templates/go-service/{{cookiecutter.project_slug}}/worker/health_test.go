@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	enums "go.temporal.io/api/enums/v1"
+	namespace "go.temporal.io/api/namespace/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckConnectionCancelledContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	// A dial that hangs like an unresponsive frontend.
+	p := &ClientProvider{dial: func(ctx context.Context, _ client.Options) (client.Client, error) {
+		<-release
+		return nil, errors.New("released")
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- CheckConnection(ctx, p) }()
+
+	select {
+	case err := <-done:
+		var connErr *ConnectionError
+		if !errors.As(err, &connErr) || connErr.Kind != ConnectionTimeout {
+			t.Fatalf("err = %v, want ConnectionError of kind timeout", err)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want it to wrap context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CheckConnection blocked on a cancelled context")
+	}
+}
+
+func TestCheckConnectionDeadlineDuringDial(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p := &ClientProvider{dial: func(ctx context.Context, _ client.Options) (client.Client, error) {
+		<-release
+		return nil, errors.New("released")
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := CheckConnection(ctx, p)
+
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) || connErr.Kind != ConnectionTimeout {
+		t.Fatalf("err = %v, want ConnectionError of kind timeout", err)
+	}
+}
+
+// healthyClient is a client.Client whose frontend is healthy and whose
+// WorkflowService knows only the namespaces in registered.
+type healthyClient struct {
+	client.Client
+	registered map[string]enums.NamespaceState
+}
+
+func (c *healthyClient) CheckHealth(context.Context, *client.CheckHealthRequest) (*client.CheckHealthResponse, error) {
+	return &client.CheckHealthResponse{}, nil
+}
+
+func (c *healthyClient) WorkflowService() workflowservice.WorkflowServiceClient {
+	return namespaceService{registered: c.registered}
+}
+
+// namespaceService answers DescribeNamespace; other methods panic via the
+// nil embedded interface.
+type namespaceService struct {
+	workflowservice.WorkflowServiceClient
+	registered map[string]enums.NamespaceState
+}
+
+func (s namespaceService) DescribeNamespace(_ context.Context, req *workflowservice.DescribeNamespaceRequest, _ ...grpc.CallOption) (*workflowservice.DescribeNamespaceResponse, error) {
+	state, ok := s.registered[req.Namespace]
+	if !ok {
+		return nil, serviceerror.NewNamespaceNotFound(req.Namespace)
+	}
+	return &workflowservice.DescribeNamespaceResponse{
+		NamespaceInfo: &namespace.NamespaceInfo{Name: req.Namespace, State: state},
+	}, nil
+}
+
+func TestCheckConnectionNamespace(t *testing.T) {
+	fake := &healthyClient{registered: map[string]enums.NamespaceState{
+		"default": enums.NAMESPACE_STATE_REGISTERED,
+		"old":     enums.NAMESPACE_STATE_DELETED,
+	}}
+
+	tests := []struct {
+		name      string
+		namespace string
+		wantErr   string
+	}{
+		{name: "default namespace"},
+		{name: "registered", namespace: "default"},
+		{name: "bogus", namespace: "bogus", wantErr: `namespace "bogus" not found`},
+		{name: "deleted", namespace: "old", wantErr: `namespace "old" is`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ClientProvider{
+				options: client.Options{Namespace: tt.namespace},
+				dial: func(context.Context, client.Options) (client.Client, error) {
+					return fake, nil
+				},
+			}
+
+			err := CheckConnection(context.Background(), p)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckConnection() = %v, want nil", err)
+				}
+				return
+			}
+			var connErr *ConnectionError
+			if !errors.As(err, &connErr) || connErr.Kind != ConnectionNamespaceNotFound {
+				t.Fatalf("err = %v, want ConnectionError of kind namespace not found", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClassifyConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ConnectionErrorKind
+	}{
+		{name: "deadline", err: context.DeadlineExceeded, want: ConnectionTimeout},
+		{name: "grpc deadline", err: status.Error(codes.DeadlineExceeded, "slow"), want: ConnectionTimeout},
+		{name: "unauthenticated", err: status.Error(codes.Unauthenticated, "bad api key"), want: ConnectionUnauthorized},
+		{name: "permission denied", err: fmt.Errorf("dial: %w", status.Error(codes.PermissionDenied, "namespace")), want: ConnectionUnauthorized},
+		{
+			name: "tls handshake",
+			err:  status.Error(codes.Unavailable, "connection error: desc = \"transport: authentication handshake failed: x509: certificate signed by unknown authority\""),
+			want: ConnectionUnauthorized,
+		},
+		{name: "unreachable", err: status.Error(codes.Unavailable, "connection refused"), want: ConnectionUnavailable},
+		{name: "other", err: errors.New("boom"), want: ConnectionUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyConnectionError(tt.err); got != tt.want {
+				t.Errorf("classifyConnectionError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
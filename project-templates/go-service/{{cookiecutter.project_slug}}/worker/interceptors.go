@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Names of the execution timers recorded by the metrics interceptor. They
+// go through the SDK metrics handler, so with NewMetricsHandler they are
+// scraped from /metrics next to the SDK's own task metrics.
+const (
+	workflowDurationMetric = "workflow_execution_duration"
+	activityDurationMetric = "activity_execution_duration"
+)
+
+// DefaultInterceptors returns the interceptors every worker runs: logging
+// of each workflow and activity execution and a duration timer per
+// workflow and activity type. Append your own (tracing, auth...) to
+// Registrations.Interceptors; they run inside these, in order.
+func DefaultInterceptors() []interceptor.WorkerInterceptor {
+	return []interceptor.WorkerInterceptor{loggingInterceptor{}, metricsInterceptor{}}
+}
+
+// loggingInterceptor logs the start and end of every workflow and activity
+// execution. It uses the SDK loggers, which skip workflow logs during
+// replay and carry the workflow and run IDs.
+type loggingInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (loggingInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &workflowLogger{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}}
+}
+
+func (loggingInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityLogger{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}}
+}
+
+type workflowLogger struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (i *workflowLogger) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	logger := workflow.GetLogger(ctx)
+	workflowType := workflow.GetInfo(ctx).WorkflowType.Name
+	start := workflow.Now(ctx)
+	logger.Info("workflow started", "workflow_type", workflowType)
+
+	result, err := i.Next.ExecuteWorkflow(ctx, in)
+	duration := workflow.Now(ctx).Sub(start)
+	if err != nil {
+		logger.Warn("workflow failed", "workflow_type", workflowType, "duration", duration, "error", err)
+	} else {
+		logger.Info("workflow completed", "workflow_type", workflowType, "duration", duration)
+	}
+	return result, err
+}
+
+type activityLogger struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (i *activityLogger) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	logger := activity.GetLogger(ctx)
+	activityType := activity.GetInfo(ctx).ActivityType.Name
+	start := time.Now()
+	logger.Info("activity started", "activity_type", activityType)
+
+	result, err := i.Next.ExecuteActivity(ctx, in)
+	duration := time.Since(start)
+	if err != nil {
+		logger.Warn("activity failed", "activity_type", activityType, "duration", duration, "error", err)
+	} else {
+		logger.Info("activity completed", "activity_type", activityType, "duration", duration)
+	}
+	return result, err
+}
+
+// metricsInterceptor records how long each execution took, tagged with the
+// workflow or activity type. Workflow durations use workflow time, and the
+// workflow metrics handler drops recordings made during replay, so each
+// execution is counted once.
+type metricsInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (metricsInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &workflowTimer{WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next}}
+}
+
+func (metricsInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityTimer{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}}
+}
+
+type workflowTimer struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (i *workflowTimer) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	start := workflow.Now(ctx)
+	result, err := i.Next.ExecuteWorkflow(ctx, in)
+	workflow.GetMetricsHandler(ctx).
+		WithTags(map[string]string{"workflow_type": workflow.GetInfo(ctx).WorkflowType.Name, "outcome": outcome(err)}).
+		Timer(workflowDurationMetric).
+		Record(workflow.Now(ctx).Sub(start))
+	return result, err
+}
+
+type activityTimer struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (i *activityTimer) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	start := time.Now()
+	result, err := i.Next.ExecuteActivity(ctx, in)
+	activity.GetMetricsHandler(ctx).
+		WithTags(map[string]string{"activity_type": activity.GetInfo(ctx).ActivityType.Name, "outcome": outcome(err)}).
+		Timer(activityDurationMetric).
+		Record(time.Since(start))
+	return result, err
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
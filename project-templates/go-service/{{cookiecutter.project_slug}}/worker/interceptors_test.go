@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// recordingInterceptor records the workflow and activity types it sees,
+// standing in for an interceptor a service adds to the defaults.
+type recordingInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	mu   sync.Mutex
+	seen []string
+}
+
+func (r *recordingInterceptor) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen = append(r.seen, name)
+}
+
+func (r *recordingInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	r.record("workflow " + workflow.GetInfo(ctx).WorkflowType.Name)
+	return next
+}
+
+func (r *recordingInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	r.record("activity " + activity.GetInfo(ctx).ActivityType.Name)
+	return next
+}
+
+func TestInterceptors(t *testing.T) {
+	var buf bytes.Buffer
+	var suite testsuite.WorkflowTestSuite
+	suite.SetLogger(log.NewStructuredLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	env := suite.NewTestWorkflowEnvironment()
+
+	custom := &recordingInterceptor{}
+	reg := DefaultRegistrations()
+	reg.Interceptors = append(reg.Interceptors, custom)
+	env.SetWorkerOptions(worker.Options{Interceptors: reg.Interceptors})
+	reg.Register(env)
+
+	env.ExecuteWorkflow(GreetingWorkflow, "Temporal")
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow error: %v", err)
+	}
+
+	logs := buf.String()
+	for _, want := range []struct{ msg, attr string }{
+		{`msg="workflow started"`, "workflow_type=GreetingWorkflow"},
+		{`msg="workflow completed"`, "workflow_type=GreetingWorkflow"},
+		{`msg="activity started"`, "activity_type=Greet"},
+		{`msg="activity completed"`, "activity_type=Greet"},
+	} {
+		if !logged(logs, want.msg, want.attr) {
+			t.Errorf("logs missing %s with %s:\n%s", want.msg, want.attr, logs)
+		}
+	}
+
+	custom.mu.Lock()
+	defer custom.mu.Unlock()
+	got := strings.Join(custom.seen, ", ")
+	for _, want := range []string{"workflow GreetingWorkflow", "activity Greet"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("custom interceptor saw %q, want it to include %q", got, want)
+		}
+	}
+}
+
+// logged reports whether a line of logs contains both msg and attr. The SDK
+// loggers add their own attributes in between.
+func logged(logs, msg, attr string) bool {
+	for _, line := range strings.Split(logs, "\n") {
+		if strings.Contains(line, msg) && strings.Contains(line, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOutcome(t *testing.T) {
+	if got := outcome(nil); got != "success" {
+		t.Errorf("outcome(nil) = %q, want success", got)
+	}
+	if got := outcome(context.Canceled); got != "failure" {
+		t.Errorf("outcome(err) = %q, want failure", got)
+	}
+}
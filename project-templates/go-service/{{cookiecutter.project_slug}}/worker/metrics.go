@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uber-go/tally/v4"
+	tallyprom "github.com/uber-go/tally/v4/prometheus"
+	"go.temporal.io/sdk/client"
+	sdktally "go.temporal.io/sdk/contrib/tally"
+)
+
+// metricsReportInterval is how often buffered SDK metrics are pushed into
+// the Prometheus registry.
+const metricsReportInterval = time.Second
+
+// NewMetricsHandler returns a handler that reports the SDK's client and
+// worker metrics (task latencies, poll counts, failures...) to reg, so they
+// are scraped from the same /metrics endpoint as the HTTP metrics. Close the
+// returned io.Closer on shutdown to flush and stop reporting.
+func NewMetricsHandler(l *slog.Logger, reg prometheus.Registerer) (client.MetricsHandler, io.Closer) {
+	reporter := tallyprom.NewReporter(tallyprom.Options{
+		Registerer:       reg,
+		DefaultTimerType: tallyprom.HistogramTimerType,
+		OnRegisterError: func(err error) {
+			l.Warn("registering temporal metric", "error", err)
+		},
+	})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter:  reporter,
+		Separator:       tallyprom.DefaultSeparator,
+		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
+	}, metricsReportInterval)
+	return sdktally.NewMetricsHandler(sdktally.NewPrometheusNamingScope(scope)), closer
+}
+
+// ConnectionMetrics tracks whether Temporal is reachable, as seen by the
+// worker's connection retry loop and by CheckConnection, so flapping
+// connectivity can be alerted on. A nil *ConnectionMetrics records nothing.
+type ConnectionMetrics struct {
+	attempts  *prometheus.CounterVec
+	connected prometheus.Gauge
+}
+
+// NewConnectionMetrics registers temporal_connection_attempts_total and
+// temporal_connected on reg.
+func NewConnectionMetrics(reg prometheus.Registerer) *ConnectionMetrics {
+	m := &ConnectionMetrics{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "temporal_connection_attempts_total",
+			Help: "Attempts to reach Temporal by the connection retry loop and health checks, by result.",
+		}, []string{"result"}),
+		connected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "temporal_connected",
+			Help: "Whether the last attempt to reach Temporal succeeded (1) or failed (0).",
+		}),
+	}
+	// Export both results from the start so rate() works before the
+	// first failure.
+	m.attempts.WithLabelValues("success")
+	m.attempts.WithLabelValues("failure")
+	reg.MustRegister(m.attempts, m.connected)
+	return m
+}
+
+// observe records one attempt that ended with err.
+func (m *ConnectionMetrics) observe(err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.attempts.WithLabelValues("failure").Inc()
+		m.connected.Set(0)
+		return
+	}
+	m.attempts.WithLabelValues("success").Inc()
+	m.connected.Set(1)
+}
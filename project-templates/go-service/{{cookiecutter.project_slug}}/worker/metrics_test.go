@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	enums "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+)
+
+func TestMetricsHandlerRegistersFamilies(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	handler, closer := NewMetricsHandler(discardLogger, reg)
+
+	// Emit what the SDK records when a worker polls and runs an activity.
+	tagged := handler.WithTags(map[string]string{"task_queue": "test", "activity_type": "Greet"})
+	tagged.Counter("temporal_activity_poll_no_task").Inc(1)
+	tagged.Timer("temporal_activity_execution_latency").Record(25 * time.Millisecond)
+	tagged.Counter("temporal_activity_execution_failed").Inc(1)
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	got := strings.Join(names, " ")
+	for _, want := range []string{
+		"temporal_activity_poll_no_task",
+		"temporal_activity_execution_latency",
+		"temporal_activity_execution_failed",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("registry families %v missing %s", names, want)
+		}
+	}
+}
+
+// connectionMetricValues returns the attempt counts by result and the
+// connected gauge from reg.
+func connectionMetricValues(t *testing.T, reg *prometheus.Registry) (success, failure, connected float64) {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			switch f.GetName() {
+			case "temporal_connection_attempts_total":
+				if m.GetLabel()[0].GetValue() == "success" {
+					success = m.GetCounter().GetValue()
+				} else {
+					failure = m.GetCounter().GetValue()
+				}
+			case "temporal_connected":
+				connected = m.GetGauge().GetValue()
+			}
+		}
+	}
+	return success, failure, connected
+}
+
+func TestConnectionMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	fail := true
+	p := &ClientProvider{dial: func(context.Context, client.Options) (client.Client, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &healthyClient{registered: map[string]enums.NamespaceState{
+			"default": enums.NAMESPACE_STATE_REGISTERED,
+		}}, nil
+	}}
+	p.SetConnectionMetrics(NewConnectionMetrics(reg))
+
+	if success, failure, _ := connectionMetricValues(t, reg); success != 0 || failure != 0 {
+		t.Fatalf("initial attempts = %v success, %v failure, want 0, 0", success, failure)
+	}
+
+	// The retry loop fails twice, then the frontend comes up.
+	attempts := 0
+	dial := func() (client.Client, error) {
+		if attempts++; attempts == 3 {
+			fail = false
+		}
+		return p.connectAttempt(context.Background())
+	}
+	noSleep := func(context.Context, time.Duration) error { return nil }
+	policy := RetryPolicy{MaxAttempts: 5, BaseInterval: time.Second, MaxInterval: time.Minute}
+	if _, err := connect(context.Background(), discardLogger, policy, dial, noSleep); err != nil {
+		t.Fatal(err)
+	}
+	if success, failure, connected := connectionMetricValues(t, reg); success != 1 || failure != 2 || connected != 1 {
+		t.Errorf("after retry loop: %v success, %v failure, connected %v; want 1, 2, 1", success, failure, connected)
+	}
+
+	// A health check against a namespace that doesn't exist fails.
+	p.options.Namespace = "bogus"
+	if err := CheckConnection(context.Background(), p); err == nil {
+		t.Fatal("CheckConnection() = nil, want error")
+	}
+	if success, failure, connected := connectionMetricValues(t, reg); success != 1 || failure != 3 || connected != 0 {
+		t.Errorf("after failed check: %v success, %v failure, connected %v; want 1, 3, 0", success, failure, connected)
+	}
+
+	p.options.Namespace = "default"
+	if err := CheckConnection(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	if success, failure, connected := connectionMetricValues(t, reg); success != 2 || failure != 3 || connected != 1 {
+		t.Errorf("after successful check: %v success, %v failure, connected %v; want 2, 3, 1", success, failure, connected)
+	}
+}
+
+func TestConnectionMetricsNil(t *testing.T) {
+	var m *ConnectionMetrics
+	m.observe(nil)
+	m.observe(errors.New("unreachable"))
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"{{cookiecutter.go_mod}}/internal/backoff"
+
+	"go.temporal.io/sdk/client"
+)
+
+// RetryPolicy controls how RunWorker retries its initial Temporal
+// connection. The wait doubles after each failed attempt, up to MaxInterval,
+// with jitter so a fleet of workers doesn't retry in lockstep.
+type RetryPolicy struct {
+	MaxAttempts  int
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  5,
+		BaseInterval: time.Second,
+		MaxInterval:  30 * time.Second,
+	}
+}
+
+func (p RetryPolicy) Validate() error {
+	var errs []error
+	if p.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("max connection attempts must be at least 1, got %d", p.MaxAttempts))
+	}
+	if p.BaseInterval <= 0 {
+		errs = append(errs, fmt.Errorf("connection retry base interval must be positive, got %v", p.BaseInterval))
+	}
+	if p.MaxInterval < p.BaseInterval {
+		errs = append(errs, fmt.Errorf("connection retry max interval %v is below the base interval %v", p.MaxInterval, p.BaseInterval))
+	}
+	return errors.Join(errs...)
+}
+
+// connect calls dial until it succeeds, policy runs out of attempts or ctx
+// is done, waiting between attempts with sleep.
+func connect(ctx context.Context, l *slog.Logger, policy RetryPolicy, dial func() (client.Client, error), sleep func(context.Context, time.Duration) error) (client.Client, error) {
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		var c client.Client
+		if c, err = dial(); err == nil {
+			return c, nil
+		}
+		l.Error("failed to connect to Temporal", "attempt", attempt, "max_attempts", policy.MaxAttempts, "error", err)
+		if attempt == policy.MaxAttempts {
+			break
+		}
+
+		wait := backoff.Wait(attempt, policy.BaseInterval, policy.MaxInterval)
+		l.Info("retrying Temporal connection", "interval", wait)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("couldn't connect to Temporal after %d attempts: %w", policy.MaxAttempts, err)
+}
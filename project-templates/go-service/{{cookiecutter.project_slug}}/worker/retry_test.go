@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"{{cookiecutter.go_mod}}/internal/backoff"
+
+	"go.temporal.io/sdk/client"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestConnectBacksOff(t *testing.T) {
+	fake := &fakeClient{}
+	dials := 0
+	dial := func() (client.Client, error) {
+		dials++
+		if dials <= 2 {
+			return nil, errors.New("connection refused")
+		}
+		return fake, nil
+	}
+	var waits []time.Duration
+	sleep := func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	policy := RetryPolicy{MaxAttempts: 5, BaseInterval: time.Second, MaxInterval: time.Minute}
+	c, err := connect(context.Background(), discardLogger, policy, dial, sleep)
+	if err != nil || c != fake {
+		t.Fatalf("connect = %v, %v, want fake client", c, err)
+	}
+	if len(waits) != 2 {
+		t.Fatalf("waited %d times, want 2", len(waits))
+	}
+	if waits[0] < 500*time.Millisecond || waits[0] > time.Second {
+		t.Errorf("first wait = %v, want within [500ms, 1s]", waits[0])
+	}
+	if waits[1] < time.Second || waits[1] > 2*time.Second {
+		t.Errorf("second wait = %v, want within [1s, 2s]", waits[1])
+	}
+	if waits[1] < waits[0] {
+		t.Errorf("waits %v shrank, want them to grow", waits)
+	}
+}
+
+func TestConnectGivesUp(t *testing.T) {
+	dial := func() (client.Client, error) { return nil, errors.New("connection refused") }
+	noSleep := func(context.Context, time.Duration) error { return nil }
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseInterval: time.Second, MaxInterval: time.Minute}
+	if _, err := connect(context.Background(), discardLogger, policy, dial, noSleep); err == nil {
+		t.Error("connect = nil error, want failure after 3 attempts")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := connect(ctx, discardLogger, policy, dial, backoff.Sleep); !errors.Is(err, context.Canceled) {
+		t.Errorf("connect with cancelled ctx = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"google.golang.org/grpc/codes"
+)
+
+// Schedule describes a Temporal schedule that starts Workflow on a cron
+// expression, a fixed interval, or both.
+type Schedule struct {
+	// ID identifies the schedule; CreateSchedule uses it to find an
+	// existing one. Workflows it starts get IDs derived from it.
+	ID string
+	// Cron holds cron expressions such as "0 3 * * *", evaluated in UTC.
+	Cron []string
+	// Every starts the workflow at a fixed interval.
+	Every time.Duration
+	// Jitter delays each start by a random amount up to Jitter, so
+	// schedules sharing a cron line don't all fire at once.
+	Jitter time.Duration
+
+	Workflow  interface{} // workflow function or registered name
+	Args      []interface{}
+	TaskQueue string
+}
+
+func (s Schedule) Validate() error {
+	var errs []error
+	if s.ID == "" {
+		errs = append(errs, errors.New("schedule ID is required"))
+	}
+	if len(s.Cron) == 0 && s.Every <= 0 {
+		errs = append(errs, fmt.Errorf("schedule %q needs a cron expression or a positive interval", s.ID))
+	}
+	if s.Every < 0 {
+		errs = append(errs, fmt.Errorf("schedule %q interval must not be negative, got %v", s.ID, s.Every))
+	}
+	if s.Workflow == nil {
+		errs = append(errs, fmt.Errorf("schedule %q has no workflow", s.ID))
+	}
+	if s.TaskQueue == "" {
+		errs = append(errs, fmt.Errorf("schedule %q has no task queue", s.ID))
+	}
+	return errors.Join(errs...)
+}
+
+func (s Schedule) spec() client.ScheduleSpec {
+	spec := client.ScheduleSpec{
+		CronExpressions: s.Cron,
+		Jitter:          s.Jitter,
+	}
+	if s.Every > 0 {
+		spec.Intervals = []client.ScheduleIntervalSpec{{Every: s.Every}}
+	}
+	return spec
+}
+
+func (s Schedule) action() *client.ScheduleWorkflowAction {
+	return &client.ScheduleWorkflowAction{
+		ID:        s.ID,
+		Workflow:  s.Workflow,
+		Args:      s.Args,
+		TaskQueue: s.TaskQueue,
+	}
+}
+
+// CreateSchedule creates the schedule s, or brings an existing schedule
+// with the same ID in line with s, so it can run on every deploy. An
+// existing schedule keeps its paused state and policies; only its spec and
+// action are replaced. The server normalizes specs it stores (cron
+// expressions come back as calendars), so they can't be compared with s
+// reliably; the update is sent regardless and is a no-op when nothing
+// changed.
+func CreateSchedule(ctx context.Context, c client.Client, s Schedule) (client.ScheduleHandle, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	handle, err := c.ScheduleClient().Create(ctx, client.ScheduleOptions{
+		ID:     s.ID,
+		Spec:   s.spec(),
+		Action: s.action(),
+	})
+	if err == nil {
+		return handle, nil
+	}
+	if !scheduleExists(err) {
+		return nil, fmt.Errorf("creating schedule %q: %w", s.ID, err)
+	}
+
+	handle = c.ScheduleClient().GetHandle(ctx, s.ID)
+	err = handle.Update(ctx, client.ScheduleUpdateOptions{
+		DoUpdate: func(in client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+			schedule := in.Description.Schedule
+			spec := s.spec()
+			schedule.Spec = &spec
+			schedule.Action = s.action()
+			return &client.ScheduleUpdate{Schedule: &schedule}, nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("updating schedule %q: %w", s.ID, err)
+	}
+	return handle, nil
+}
+
+// scheduleExists reports whether a Create error means the schedule is
+// already there. The SDK translates the server's AlreadyExists into
+// ErrScheduleAlreadyRunning; the status check covers errors that reach us
+// untranslated.
+func scheduleExists(err error) bool {
+	return errors.Is(err, temporal.ErrScheduleAlreadyRunning) || grpcStatus(err).Code() == codes.AlreadyExists
+}
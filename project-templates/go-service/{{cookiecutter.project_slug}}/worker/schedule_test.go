@@ -0,0 +1,188 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// scheduleClient is a client.Client whose ScheduleClient records what
+// CreateSchedule sends. existing, when set, is the schedule already stored
+// under its ID.
+type scheduleClient struct {
+	client.Client
+	existing *client.Schedule
+
+	created *client.ScheduleOptions
+	updated *client.Schedule
+}
+
+func (c *scheduleClient) ScheduleClient() client.ScheduleClient {
+	return &fakeScheduleClient{c: c}
+}
+
+type fakeScheduleClient struct {
+	client.ScheduleClient
+	c *scheduleClient
+}
+
+func (f *fakeScheduleClient) Create(_ context.Context, opts client.ScheduleOptions) (client.ScheduleHandle, error) {
+	if f.c.existing != nil {
+		return nil, temporal.ErrScheduleAlreadyRunning
+	}
+	f.c.created = &opts
+	return &fakeScheduleHandle{c: f.c, id: opts.ID}, nil
+}
+
+func (f *fakeScheduleClient) GetHandle(_ context.Context, id string) client.ScheduleHandle {
+	return &fakeScheduleHandle{c: f.c, id: id}
+}
+
+type fakeScheduleHandle struct {
+	client.ScheduleHandle
+	c  *scheduleClient
+	id string
+}
+
+func (h *fakeScheduleHandle) GetID() string { return h.id }
+
+func (h *fakeScheduleHandle) Update(_ context.Context, opts client.ScheduleUpdateOptions) error {
+	update, err := opts.DoUpdate(client.ScheduleUpdateInput{
+		Description: client.ScheduleDescription{Schedule: *h.c.existing},
+	})
+	if err != nil {
+		return err
+	}
+	h.c.updated = update.Schedule
+	return nil
+}
+
+func greetingSchedule() Schedule {
+	return Schedule{
+		ID:        "nightly-greeting",
+		Cron:      []string{"0 3 * * *"},
+		Every:     6 * time.Hour,
+		Jitter:    time.Minute,
+		Workflow:  GreetingWorkflow,
+		Args:      []interface{}{"Temporal"},
+		TaskQueue: "greetings",
+	}
+}
+
+func TestCreateSchedule(t *testing.T) {
+	c := &scheduleClient{}
+	handle, err := CreateSchedule(context.Background(), c, greetingSchedule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handle.GetID() != "nightly-greeting" {
+		t.Errorf("handle ID = %q, want nightly-greeting", handle.GetID())
+	}
+	if c.created == nil {
+		t.Fatal("schedule was not created")
+	}
+
+	wantSpec := client.ScheduleSpec{
+		CronExpressions: []string{"0 3 * * *"},
+		Intervals:       []client.ScheduleIntervalSpec{{Every: 6 * time.Hour}},
+		Jitter:          time.Minute,
+	}
+	if !reflect.DeepEqual(c.created.Spec, wantSpec) {
+		t.Errorf("spec = %+v, want %+v", c.created.Spec, wantSpec)
+	}
+	action, ok := c.created.Action.(*client.ScheduleWorkflowAction)
+	if !ok {
+		t.Fatalf("action = %T, want *client.ScheduleWorkflowAction", c.created.Action)
+	}
+	if action.ID != "nightly-greeting" || action.TaskQueue != "greetings" {
+		t.Errorf("action ID, task queue = %q, %q", action.ID, action.TaskQueue)
+	}
+
+	// The scheduled action must be runnable as given: execute it in the
+	// test environment with the recorded workflow and arguments.
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(GreetingWorkflow)
+	env.RegisterActivity(&Activities{})
+	env.ExecuteWorkflow(action.Workflow, action.Args...)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("scheduled workflow error: %v", err)
+	}
+	var greeting string
+	if err := env.GetWorkflowResult(&greeting); err != nil {
+		t.Fatal(err)
+	}
+	if greeting != "Hello, Temporal!" {
+		t.Errorf("greeting = %q, want %q", greeting, "Hello, Temporal!")
+	}
+}
+
+func TestCreateScheduleUpdatesExisting(t *testing.T) {
+	c := &scheduleClient{existing: &client.Schedule{
+		Action: &client.ScheduleWorkflowAction{ID: "nightly-greeting", Workflow: "Old", TaskQueue: "old"},
+		Spec:   &client.ScheduleSpec{CronExpressions: []string{"0 1 * * *"}},
+		State:  &client.ScheduleState{Paused: true},
+	}}
+	s := greetingSchedule()
+	s.Every = 0
+
+	if _, err := CreateSchedule(context.Background(), c, s); err != nil {
+		t.Fatal(err)
+	}
+	if c.updated == nil {
+		t.Fatal("existing schedule was not updated")
+	}
+	if got := c.updated.Spec.CronExpressions; !reflect.DeepEqual(got, s.Cron) {
+		t.Errorf("cron = %v, want %v", got, s.Cron)
+	}
+	if len(c.updated.Spec.Intervals) != 0 {
+		t.Errorf("intervals = %v, want none", c.updated.Spec.Intervals)
+	}
+	if action := c.updated.Action.(*client.ScheduleWorkflowAction); action.TaskQueue != "greetings" {
+		t.Errorf("task queue = %q, want greetings", action.TaskQueue)
+	}
+	if !c.updated.State.Paused {
+		t.Error("update unpaused the schedule")
+	}
+}
+
+func TestCreateScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Schedule)
+	}{
+		{name: "no ID", modify: func(s *Schedule) { s.ID = "" }},
+		{name: "no timing", modify: func(s *Schedule) { s.Cron, s.Every = nil, 0 }},
+		{name: "negative interval", modify: func(s *Schedule) { s.Cron, s.Every = nil, -time.Second }},
+		{name: "no workflow", modify: func(s *Schedule) { s.Workflow = nil }},
+		{name: "no task queue", modify: func(s *Schedule) { s.TaskQueue = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := greetingSchedule()
+			tt.modify(&s)
+			c := &scheduleClient{}
+			if _, err := CreateSchedule(context.Background(), c, s); err == nil {
+				t.Fatal("expected an error")
+			}
+			if c.created != nil {
+				t.Error("invalid schedule was created")
+			}
+		})
+	}
+}
+
+func TestScheduleExists(t *testing.T) {
+	if !scheduleExists(temporal.ErrScheduleAlreadyRunning) {
+		t.Error("ErrScheduleAlreadyRunning not recognized")
+	}
+	if scheduleExists(errors.New("unavailable")) {
+		t.Error("unrelated error treated as an existing schedule")
+	}
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
+)
+
+// StartRequest describes a workflow to start by name, as the workflow
+// start command does.
+type StartRequest struct {
+	// WorkflowType is the registered workflow name, e.g. GreetingWorkflow.
+	WorkflowType string
+	// WorkflowID defaults to the type followed by a random UUID.
+	WorkflowID string
+	TaskQueue  string
+	// Args are the workflow arguments, one JSON value each.
+	Args []string
+	// Wait blocks until the workflow completes and returns its result.
+	Wait bool
+}
+
+// StartResult identifies a started workflow run. Result is only set when
+// the request waited for it.
+type StartResult struct {
+	WorkflowID string      `json:"workflow_id"`
+	RunID      string      `json:"run_id"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// workflowStarter is the part of client.Client StartWorkflow uses; a
+// ClientProvider also satisfies it.
+type workflowStarter interface {
+	ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error)
+}
+
+// StartWorkflow starts the workflow described by req with the client from
+// clients and, if req.Wait, waits for its result.
+func StartWorkflow(ctx context.Context, clients *ClientProvider, req StartRequest) (StartResult, error) {
+	return startWorkflow(ctx, clients, req)
+}
+
+func startWorkflow(ctx context.Context, c workflowStarter, req StartRequest) (StartResult, error) {
+	if req.WorkflowType == "" {
+		return StartResult{}, errors.New("workflow type is required")
+	}
+	if req.TaskQueue == "" {
+		return StartResult{}, errors.New("task queue is required")
+	}
+	args, err := decodeArgs(req.Args)
+	if err != nil {
+		return StartResult{}, err
+	}
+	id := req.WorkflowID
+	if id == "" {
+		id = req.WorkflowType + "-" + uuid.NewString()
+	}
+
+	run, err := c.ExecuteWorkflow(ctx, client.StartWorkflowOptions{ID: id, TaskQueue: req.TaskQueue}, req.WorkflowType, args...)
+	if err != nil {
+		return StartResult{}, fmt.Errorf("starting workflow %s: %w", req.WorkflowType, err)
+	}
+	res := StartResult{WorkflowID: run.GetID(), RunID: run.GetRunID()}
+	if !req.Wait {
+		return res, nil
+	}
+	if err := run.Get(ctx, &res.Result); err != nil {
+		return res, fmt.Errorf("workflow %s failed: %w", res.WorkflowID, err)
+	}
+	return res, nil
+}
+
+// decodeArgs parses each argument as a JSON value. Numbers are kept as
+// json.Number so large integers survive the round trip through the data
+// converter.
+func decodeArgs(raw []string) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(raw))
+	for i, r := range raw {
+		dec := json.NewDecoder(bytes.NewReader([]byte(r)))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("argument %d is not valid JSON: %w", i+1, err)
+		}
+		if dec.More() {
+			return nil, fmt.Errorf("argument %d holds more than one JSON value", i+1)
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
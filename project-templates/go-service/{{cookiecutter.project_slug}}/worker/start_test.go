@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// envStarter starts workflows in a test environment, standing in for a
+// client connected to a server running this service's worker.
+type envStarter struct {
+	env     *testsuite.TestWorkflowEnvironment
+	options client.StartWorkflowOptions
+}
+
+func (s *envStarter) ExecuteWorkflow(_ context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	s.options = options
+	s.env.ExecuteWorkflow(workflow, args...)
+	return envRun{env: s.env, id: options.ID}, nil
+}
+
+type envRun struct {
+	client.WorkflowRun
+	env *testsuite.TestWorkflowEnvironment
+	id  string
+}
+
+func (r envRun) GetID() string    { return r.id }
+func (r envRun) GetRunID() string { return "run-1" }
+
+func (r envRun) Get(_ context.Context, valuePtr interface{}) error {
+	if err := r.env.GetWorkflowError(); err != nil {
+		return err
+	}
+	return r.env.GetWorkflowResult(valuePtr)
+}
+
+func TestStartWorkflow(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	DefaultRegistrations().Register(env)
+	starter := &envStarter{env: env}
+
+	res, err := startWorkflow(context.Background(), starter, StartRequest{
+		WorkflowType: "GreetingWorkflow",
+		TaskQueue:    "greetings",
+		Args:         []string{`"Temporal"`},
+		Wait:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not run")
+	}
+	if !strings.HasPrefix(res.WorkflowID, "GreetingWorkflow-") || res.RunID != "run-1" {
+		t.Errorf("IDs = %q, %q", res.WorkflowID, res.RunID)
+	}
+	if starter.options.TaskQueue != "greetings" || starter.options.ID != res.WorkflowID {
+		t.Errorf("start options = %+v", starter.options)
+	}
+	if res.Result != "Hello, Temporal!" {
+		t.Errorf("result = %v, want %q", res.Result, "Hello, Temporal!")
+	}
+}
+
+func TestStartWorkflowNoWait(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	DefaultRegistrations().Register(env)
+
+	res, err := startWorkflow(context.Background(), &envStarter{env: env}, StartRequest{
+		WorkflowType: "GreetingWorkflow",
+		WorkflowID:   "greet-once",
+		TaskQueue:    "greetings",
+		Args:         []string{`"Temporal"`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.WorkflowID != "greet-once" || res.Result != nil {
+		t.Errorf("result = %+v, want ID greet-once and no result", res)
+	}
+}
+
+func TestStartWorkflowInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     StartRequest
+		wantErr string
+	}{
+		{name: "no type", req: StartRequest{TaskQueue: "q"}, wantErr: "workflow type is required"},
+		{name: "no task queue", req: StartRequest{WorkflowType: "W"}, wantErr: "task queue is required"},
+		{name: "bad JSON", req: StartRequest{WorkflowType: "W", TaskQueue: "q", Args: []string{"Temporal"}}, wantErr: "argument 1 is not valid JSON"},
+		{name: "two values", req: StartRequest{WorkflowType: "W", TaskQueue: "q", Args: []string{`1 2`}}, wantErr: "more than one JSON value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A nil starter: validation must fail before anything starts.
+			_, err := startWorkflow(context.Background(), nil, tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeArgs(t *testing.T) {
+	args, err := decodeArgs([]string{`"name"`, `9007199254740993`, `{"a":[true,null]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		"name",
+		json.Number("9007199254740993"),
+		map[string]interface{}{"a": []interface{}{true, nil}},
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("decodeArgs = %#v, want %#v", args, want)
+	}
+}
@@ -2,76 +2,210 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"time"
+
+	"{{cookiecutter.go_mod}}/internal/backoff"
 
 	"go.temporal.io/sdk/client"
-	sdklog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
+	"golang.org/x/sync/errgroup"
 )
 
-// RunWorker starts the Temporal worker with the specified options.
-func RunWorker(ctx context.Context, l *slog.Logger, temporalAddr, namespace, taskQueue string) error {
-	temporalLogger := sdklog.NewStructuredLogger(l)
-
-	// Connect to Temporal with retries
-	var c client.Client
-	var err error
-	maxRetries := 5
-	retryInterval := 5 * time.Second
-
-	for i := 0; i < maxRetries; i++ {
-		c, err = client.Dial(client.Options{
-			Logger:    temporalLogger,
-			HostPort:  temporalAddr,
-			Namespace: namespace,
-		})
-		if err == nil {
-			l.Info("connected to Temporal", "address", temporalAddr, "namespace", namespace)
-			break
-		}
-		l.Error("failed to connect to Temporal", "attempt", i+1, "max_attempts", maxRetries, "error", err)
-		if i < maxRetries-1 {
-			l.Info("retrying Temporal connection", "interval", retryInterval)
-			time.Sleep(retryInterval)
-		}
+// Registrations lists the workflows and activities a worker serves, and
+// the interceptors wrapping their executions. Activities may be functions
+// or a struct pointer whose exported methods are each registered as an
+// activity.
+type Registrations struct {
+	Workflows    []interface{}
+	Activities   []interface{}
+	Interceptors []interceptor.WorkerInterceptor
+}
+
+// DefaultRegistrations returns the workflows and activities this service
+// runs.
+func DefaultRegistrations() Registrations {
+	return Registrations{
+		Workflows:    []interface{}{GreetingWorkflow},
+		Activities:   []interface{}{&Activities{}},
+		Interceptors: DefaultInterceptors(),
 	}
-	if err != nil {
-		return fmt.Errorf("couldn't connect to Temporal after %d attempts: %w", maxRetries, err)
+}
+
+// registry is the subset of worker.Worker used for registration, also
+// satisfied by the testsuite environments.
+type registry interface {
+	RegisterWorkflow(w interface{})
+	RegisterActivity(a interface{})
+}
+
+// Register registers every workflow and activity with r.
+func (reg Registrations) Register(r registry) {
+	for _, w := range reg.Workflows {
+		r.RegisterWorkflow(w)
+	}
+	for _, a := range reg.Activities {
+		r.RegisterActivity(a)
 	}
-	defer c.Close()
+}
+
+// Tuning sizes a worker per deployment.
+type Tuning struct {
+	MaxConcurrentActivityExecutionSize     int
+	MaxConcurrentWorkflowTaskExecutionSize int
+	WorkerActivitiesPerSecond              float64
+}
 
-	// Create the worker
-	w := worker.New(c, taskQueue, worker.Options{})
+// DefaultTuning returns the Temporal SDK defaults.
+func DefaultTuning() Tuning {
+	return Tuning{
+		MaxConcurrentActivityExecutionSize:     1000,
+		MaxConcurrentWorkflowTaskExecutionSize: 1000,
+		WorkerActivitiesPerSecond:              100000,
+	}
+}
 
-	// Register workflows
-	// w.RegisterWorkflow(YourWorkflow)
+// Validate reports every non-positive value. The SDK also rejects a
+// workflow task concurrency of 1.
+func (t Tuning) Validate() error {
+	var errs []error
+	if t.MaxConcurrentActivityExecutionSize <= 0 {
+		errs = append(errs, fmt.Errorf("max concurrent activity executions must be positive, got %d", t.MaxConcurrentActivityExecutionSize))
+	}
+	if t.MaxConcurrentWorkflowTaskExecutionSize < 2 {
+		errs = append(errs, fmt.Errorf("max concurrent workflow task executions must be at least 2, got %d", t.MaxConcurrentWorkflowTaskExecutionSize))
+	}
+	if t.WorkerActivitiesPerSecond <= 0 {
+		errs = append(errs, fmt.Errorf("worker activities per second must be positive, got %v", t.WorkerActivitiesPerSecond))
+	}
+	return errors.Join(errs...)
+}
 
-	// Register activities
-	// w.RegisterActivity(YourActivity)
+func (t Tuning) workerOptions() worker.Options {
+	return worker.Options{
+		MaxConcurrentActivityExecutionSize:     t.MaxConcurrentActivityExecutionSize,
+		MaxConcurrentWorkflowTaskExecutionSize: t.MaxConcurrentWorkflowTaskExecutionSize,
+		WorkerActivitiesPerSecond:              t.WorkerActivitiesPerSecond,
+	}
+}
 
-	l.Info("starting worker", "task_queue", taskQueue)
-	err = w.Run(worker.InterruptCh())
+// RunWorker starts the Temporal worker with the specified options, serving
+// the workflows and activities in reg until ctx is cancelled. It doesn't
+// watch for signals itself, so the caller decides when the worker stops
+// relative to the rest of the process; cancel ctx on SIGINT and SIGTERM.
+// The caller owns clients and closes it.
+func RunWorker(ctx context.Context, l *slog.Logger, clients *ClientProvider, taskQueue string, reg Registrations, tuning Tuning, retry RetryPolicy) error {
+	return RunWorkers(ctx, l, clients, []Queue{{TaskQueue: taskQueue, Registrations: reg}}, tuning, retry)
+}
+
+// Queue is a task queue a worker process polls and what it serves there.
+type Queue struct {
+	TaskQueue     string
+	Registrations Registrations
+}
+
+// RunWorkers is RunWorker for several task queues: it starts one worker
+// per queue, all sharing the client from clients and the same tuning, and
+// stops them together when ctx is cancelled.
+// If one worker fails the others are stopped and its error is returned.
+func RunWorkers(ctx context.Context, l *slog.Logger, clients *ClientProvider, queues []Queue, tuning Tuning, retry RetryPolicy) error {
+	if err := errors.Join(validateQueues(queues), tuning.Validate(), retry.Validate()); err != nil {
+		return fmt.Errorf("invalid worker options: %w", err)
+	}
+
+	dial := func() (client.Client, error) { return clients.connectAttempt(ctx) }
+	c, err := connect(ctx, l, retry, dial, backoff.Sleep)
+	if err != nil {
+		return err
+	}
+	l.Info("connected to Temporal", "address", clients.options.HostPort, "namespace", clients.options.Namespace)
+
+	newWorker := func(q Queue) queueWorker {
+		opts := tuning.workerOptions()
+		opts.Interceptors = q.Registrations.Interceptors
+		return worker.New(c, q.TaskQueue, opts)
+	}
+	err = runQueues(ctx, l, queues, newWorker, nil)
 	l.Info("worker stopped")
 	return err
 }
 
-// CheckConnection attempts to connect to Temporal and returns an error if it fails.
-// Used for health checks.
-func CheckConnection(ctx context.Context, l *slog.Logger, temporalAddr, namespace string) error {
-	temporalLogger := sdklog.NewStructuredLogger(l)
+func validateQueues(queues []Queue) error {
+	if len(queues) == 0 {
+		return errors.New("at least one task queue is required")
+	}
+	var errs []error
+	seen := make(map[string]bool, len(queues))
+	for _, q := range queues {
+		switch {
+		case q.TaskQueue == "":
+			errs = append(errs, errors.New("task queue name must not be empty"))
+		case seen[q.TaskQueue]:
+			errs = append(errs, fmt.Errorf("task queue %q is listed more than once", q.TaskQueue))
+		}
+		seen[q.TaskQueue] = true
+	}
+	return errors.Join(errs...)
+}
 
-	c, err := client.Dial(client.Options{
-		Logger:    temporalLogger,
-		HostPort:  temporalAddr,
-		Namespace: namespace,
-	})
-	if err != nil {
-		return fmt.Errorf("health check failed: %w", err)
+// queueWorker is the part of worker.Worker runQueues uses.
+type queueWorker interface {
+	registry
+	runner
+}
+
+// runQueues registers and runs a worker per queue under one errgroup, so
+// the first to fail, ctx or interrupt stops them all. A nil interrupt
+// never fires.
+func runQueues(ctx context.Context, l *slog.Logger, queues []Queue, newWorker func(Queue) queueWorker, interrupt <-chan interface{}) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, q := range queues {
+		w := newWorker(q)
+		q.Registrations.Register(w)
+		l.Info("starting worker", "task_queue", q.TaskQueue,
+			"workflows", len(q.Registrations.Workflows), "activities", len(q.Registrations.Activities))
+		g.Go(func() error {
+			if err := runUntilDone(gctx, w, nil); err != nil {
+				return fmt.Errorf("worker for task queue %q: %w", q.TaskQueue, err)
+			}
+			return nil
+		})
 	}
-	c.Close()
+	return g.Wait()
+}
 
-	l.Info("health check successful")
-	return nil
+// runner is the part of worker.Worker that runs it until interrupted.
+type runner interface {
+	Run(interruptCh <-chan interface{}) error
+}
+
+// runUntilDone runs w until ctx is done or interrupt fires, whichever comes
+// first, so the worker can share a shutdown signal with the HTTP server. If
+// w stops on its own, the goroutine relaying the signal exits with it.
+func runUntilDone(ctx context.Context, w runner, interrupt <-chan interface{}) error {
+	stop := make(chan interface{}, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-interrupt:
+		case <-done:
+			return
+		}
+		stop <- struct{}{}
+	}()
+	return w.Run(stop)
 }
@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTuningWorkerOptions(t *testing.T) {
+	tuning := Tuning{
+		MaxConcurrentActivityExecutionSize:     50,
+		MaxConcurrentWorkflowTaskExecutionSize: 20,
+		WorkerActivitiesPerSecond:              12.5,
+	}
+	opts := tuning.workerOptions()
+	if opts.MaxConcurrentActivityExecutionSize != 50 ||
+		opts.MaxConcurrentWorkflowTaskExecutionSize != 20 ||
+		opts.WorkerActivitiesPerSecond != 12.5 {
+		t.Errorf("workerOptions() = %+v, want values from %+v", opts, tuning)
+	}
+}
+
+func TestTuningValidate(t *testing.T) {
+	if err := DefaultTuning().Validate(); err != nil {
+		t.Errorf("DefaultTuning().Validate() = %v, want nil", err)
+	}
+
+	err := Tuning{MaxConcurrentWorkflowTaskExecutionSize: 1}.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want error")
+	}
+	for _, want := range []string{"activity executions", "workflow task executions", "activities per second"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %q, missing %q", err, want)
+		}
+	}
+}
+
+// blockingRunner runs until its interrupt channel fires.
+type blockingRunner struct{}
+
+func (blockingRunner) Run(interruptCh <-chan interface{}) error {
+	<-interruptCh
+	return nil
+}
+
+func TestRunUntilDoneStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runUntilDone(ctx, blockingRunner{}, make(chan interface{})) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("runUntilDone = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runUntilDone did not return after cancel")
+	}
+}
+
+// failingRunner stops straight away with err, as a worker that can't poll.
+type failingRunner struct{ err error }
+
+func (r failingRunner) Run(<-chan interface{}) error { return r.err }
+
+func TestRunUntilDoneReleasesRelayWhenRunFails(t *testing.T) {
+	failure := errors.New("poller failed")
+	before := runtime.NumGoroutine()
+	for range 100 {
+		// Neither ctx nor the nil interrupt ever fire.
+		if err := runUntilDone(context.Background(), failingRunner{failure}, nil); !errors.Is(err, failure) {
+			t.Fatalf("runUntilDone = %v, want %v", err, failure)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after, %d before: the signal relay leaked", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// queueRecorder is a queueWorker that records its registrations and runs
+// until interrupted, or fails straight away with err.
+type queueRecorder struct {
+	mu         sync.Mutex
+	workflows  []interface{}
+	activities []interface{}
+	err        error
+}
+
+func (r *queueRecorder) RegisterWorkflow(w interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workflows = append(r.workflows, w)
+}
+
+func (r *queueRecorder) RegisterActivity(a interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.activities = append(r.activities, a)
+}
+
+func (r *queueRecorder) Run(interruptCh <-chan interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	<-interruptCh
+	return nil
+}
+
+func TestRunQueuesRegistersEachQueue(t *testing.T) {
+	queues := []Queue{
+		{TaskQueue: "greetings", Registrations: DefaultRegistrations()},
+		{TaskQueue: "reports", Registrations: Registrations{Activities: []interface{}{&Activities{}}}},
+	}
+	workers := map[string]*queueRecorder{}
+	newWorker := func(q Queue) queueWorker {
+		w := &queueRecorder{}
+		workers[q.TaskQueue] = w
+		return w
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runQueues(ctx, discardLogger, queues, newWorker, nil) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runQueues = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runQueues did not return after cancel")
+	}
+
+	if w := workers["greetings"]; w == nil || len(w.workflows) != 1 || len(w.activities) != 1 {
+		t.Errorf("greetings worker = %+v, want 1 workflow and 1 activity", w)
+	}
+	if w := workers["reports"]; w == nil || len(w.workflows) != 0 || len(w.activities) != 1 {
+		t.Errorf("reports worker = %+v, want 0 workflows and 1 activity", w)
+	}
+}
+
+func TestRunQueuesFailureStopsOthers(t *testing.T) {
+	queues := []Queue{{TaskQueue: "healthy"}, {TaskQueue: "broken"}}
+	newWorker := func(q Queue) queueWorker {
+		if q.TaskQueue == "broken" {
+			return &queueRecorder{err: errors.New("poller failed")}
+		}
+		return &queueRecorder{}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- runQueues(context.Background(), discardLogger, queues, newWorker, nil) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), `task queue "broken"`) {
+			t.Fatalf("runQueues = %v, want the broken queue's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("healthy worker kept running after the other failed")
+	}
+}
+
+func TestRunQueuesStopsOnInterrupt(t *testing.T) {
+	interrupt := make(chan interface{}, 1)
+	newWorker := func(Queue) queueWorker { return &queueRecorder{} }
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runQueues(context.Background(), discardLogger, []Queue{{TaskQueue: "a"}, {TaskQueue: "b"}}, newWorker, interrupt)
+	}()
+	interrupt <- struct{}{}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("runQueues = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runQueues did not return after interrupt")
+	}
+}
+
+func TestValidateQueues(t *testing.T) {
+	tests := []struct {
+		name    string
+		queues  []Queue
+		wantErr string
+	}{
+		{name: "valid", queues: []Queue{{TaskQueue: "a"}, {TaskQueue: "b"}}},
+		{name: "none", wantErr: "at least one task queue"},
+		{name: "empty name", queues: []Queue{{TaskQueue: ""}}, wantErr: "must not be empty"},
+		{name: "duplicate", queues: []Queue{{TaskQueue: "a"}, {TaskQueue: "a"}}, wantErr: `"a" is listed more than once`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateQueues(tt.queues)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateQueues = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateQueues = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}